@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	stdErrors "errors"
 	"strings"
 
@@ -10,6 +11,8 @@ import (
 
 const USER_LOCAL_KEY = "x-user"
 
+type userContextKey struct{}
+
 type WebAuthClient interface {
 	GetUserFromToken(token string) (any, error)
 }
@@ -89,6 +92,7 @@ func (m *WebAuthManager) AuthGuardMiddleware(ctx *fiber.Ctx) error {
 	if user == nil {
 		return errors.NewHttpError("WEB_AUTH_MANAGER__User_EMPTY", stdErrors.New("unauthorized"), fiber.ErrUnauthorized.Code, fiber.StatusUnauthorized)
 	}
+	ctx.SetUserContext(WithUser(ctx.UserContext(), user))
 
 	return ctx.Next()
 }
@@ -99,3 +103,19 @@ func (m *WebAuthManager) User(ctx *fiber.Ctx) any {
 
 	return appUser
 }
+
+// WithUser returns a copy of ctx carrying the authenticated user, e.g. for a
+// background job acting on behalf of a user.
+func WithUser(ctx context.Context, user any) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFrom returns the authenticated user of a context.Context, such as the
+// one returned by xgo.RequestContext, or nil.
+func UserFrom(ctx context.Context) any {
+	if ctx == nil {
+		return nil
+	}
+
+	return ctx.Value(userContextKey{})
+}
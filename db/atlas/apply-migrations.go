@@ -2,13 +2,28 @@ package atlas
 
 import (
 	"context"
+	"fmt"
 	"io/fs"
 	"log"
 
 	"ariga.io/atlas-go-sdk/atlasexec"
 )
 
+// ApplyMigrations applies the migrations of dir with `atlas migrate apply`
+// and exits on failure. See ApplyMigrationsContext for an error-returning variant,
+// and migrate.New for a runner without the atlas CLI.
 func ApplyMigrations(config AtlasConfig, dir fs.FS) {
+	res, err := ApplyMigrationsContext(context.Background(), config, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Applied %d migrations\n", len(res.Applied))
+}
+
+// ApplyMigrationsContext applies the migrations of dir with `atlas migrate apply`.
+// The atlas CLI must be installed.
+func ApplyMigrationsContext(ctx context.Context, config AtlasConfig, dir fs.FS) (*atlasexec.MigrateApply, error) {
 
 	// Define the execution context, supplying a migration directory
 	// and potentially an `atlas.hcl` configuration file using `atlasexec.WithHCL`.
@@ -19,7 +34,7 @@ func ApplyMigrations(config AtlasConfig, dir fs.FS) {
 		),
 	)
 	if err != nil {
-		log.Fatalf("failed to load working directory: %v", err)
+		return nil, fmt.Errorf("failed to load working directory: %w", err)
 	}
 	// atlasexec works on a temporary directory, so we need to close it
 	defer workdir.Close()
@@ -27,18 +42,17 @@ func ApplyMigrations(config AtlasConfig, dir fs.FS) {
 	// Initialize the client.
 	client, err := atlasexec.NewClient(workdir.Path(), "atlas")
 	if err != nil {
-		log.Fatalf("failed to initialize client: %v", err)
+		return nil, fmt.Errorf("failed to initialize client: %w", err)
 	}
 
-	// Run `atlas migrate apply` on a SQLite database under /tmp.
-	res, err := client.MigrateApply(context.Background(), &atlasexec.MigrateApplyParams{
+	res, err := client.MigrateApply(ctx, &atlasexec.MigrateApplyParams{
 		URL:             config.URL,
 		RevisionsSchema: config.RevisionsSchema,
 	})
 
 	if err != nil {
-		log.Fatalf("failed to apply migrations: %v", err)
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
 	}
 
-	log.Printf("Applied %d migrations\n", len(res.Applied))
+	return res, nil
 }
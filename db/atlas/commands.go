@@ -0,0 +1,386 @@
+package atlas
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	xgomigrate "github.com/anoaland/xgo/db/migrate"
+)
+
+var (
+	// errDrift is returned by the drift command when the database differs from the models.
+	errDrift = errors.New("the database schema drifted from the models")
+	// errLint is returned by the lint command when a migration is destructive.
+	errLint = errors.New("destructive changes found")
+	// errUsage is returned for invalid flags or arguments.
+	errUsage = errors.New("invalid usage")
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	flags       func(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{}
+
+var commandOrder = []string{"status", "diff", "lint", "drift", "hash"}
+
+func init() {
+	for _, cmd := range []command{
+		{
+			name:        "status",
+			usage:       "status [flags]",
+			description: "Print the applied and pending migrations, recorded by the atlas CLI or, with -runner xgo, by the migrate package.",
+			flags:       statusCommand,
+		},
+		{
+			name:        "diff",
+			usage:       "diff [flags] [name]",
+			description: "Write a new migration with the changes between the migration directory and the models.",
+			flags:       diffCommand,
+		},
+		{
+			name:        "lint",
+			usage:       "lint [flags]",
+			description: "Flag destructive and risky changes in the latest migrations.",
+			flags:       lintCommand,
+		},
+		{
+			name:        "drift",
+			usage:       "drift [flags]",
+			description: "Compare the schema of the live database to the models, failing when they differ.",
+			flags:       driftCommand,
+		},
+		{
+			name:        "hash",
+			usage:       "hash [flags]",
+			description: "Rebuild the atlas.sum checksum file of the migration directory.",
+			flags:       hashCommand,
+		},
+	} {
+		commands[cmd.name] = cmd
+	}
+}
+
+func (c command) run(ctx context.Context, options AtlasOptions, args []string) error {
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s\n\n%s\n\nFlags:\n", c.usage, c.description)
+		flags.PrintDefaults()
+	}
+
+	run := c.flags(options, flags)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	return run(ctx, flags.Args())
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: go run ./atlas <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  m\tPrint the DDL of the models, for the atlas.hcl external_schema.")
+	fmt.Fprintln(tw, "  e\tPrint the atlas config as JSON, for the atlas.hcl external data.")
+	for _, name := range commandOrder {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].description)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'go run ./atlas <command> -h' for the flags of a command.")
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	}
+
+	return 1
+}
+
+// stringsFlag is a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+const defaultMigrationsDir = "database/migrations"
+
+func statusCommand(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error {
+	dir := flags.String("dir", defaultMigrationsDir, "migration directory")
+	url := flags.String("url", options.config.URL, "URL of the database")
+	runner := flags.String("runner", "atlas", "runner applying the migrations: \"atlas\" reads the atlas_schema_revisions table of the atlas CLI, \"xgo\" the revisions table of the migrate package")
+	revisionsSchema := flags.String("revisions-schema", options.config.RevisionsSchema, "schema of the revisions table")
+	revisionsTable := flags.String("revisions-table", "", "revisions table of the xgo runner (default \"xgo_schema_revisions\")")
+
+	return func(ctx context.Context, args []string) error {
+		if *runner != "atlas" && *runner != "xgo" {
+			return fmt.Errorf("%w: unknown runner '%s', expected atlas or xgo", errUsage, *runner)
+		}
+
+		db, err := openGorm(options.dialect, *url)
+		if err != nil {
+			return err
+		}
+		defer closeGorm(db)
+
+		var statuses []migrationStatus
+		if *runner == "atlas" {
+			if statuses, err = atlasStatuses(ctx, db, options.dialect, os.DirFS(*dir), *revisionsSchema); err != nil {
+				return err
+			}
+		} else {
+			migrator, err := xgomigrate.New(db, os.DirFS(*dir), xgomigrate.Options{
+				RevisionsSchema: *revisionsSchema,
+				RevisionsTable:  *revisionsTable,
+			})
+			if err != nil {
+				return err
+			}
+
+			xgoStatuses, err := migrator.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, status := range xgoStatuses {
+				statuses = append(statuses, migrationStatus{Status: status})
+			}
+		}
+
+		var applied, pending int
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tDESCRIPTION\tSTATUS")
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Error != "":
+				state = "failed: " + strings.Join(strings.Fields(status.Error), " ")
+			case status.Missing:
+				state = "applied, missing from directory"
+			case status.Modified:
+				state = "applied, modified since"
+			case status.Applied:
+				state = "applied " + status.AppliedAt.Local().Format(time.DateTime)
+			case status.OutOfOrder:
+				state = "pending, older than the latest applied migration"
+			}
+
+			if status.Applied {
+				applied++
+			} else {
+				pending++
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", status.Version, status.Description, state)
+		}
+		tw.Flush()
+
+		fmt.Printf("\n%d applied, %d pending\n", applied, pending)
+
+		return nil
+	}
+}
+
+func diffCommand(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error {
+	dir := flags.String("dir", defaultMigrationsDir, "migration directory")
+	devURL := flags.String("dev-url", options.config.DevUrl, "URL of an empty dev database, used to compute the schemas")
+	var schemas stringsFlag
+	flags.Var(&schemas, "schema", "schema to compare, can be repeated (default: the connected schema)")
+
+	return func(ctx context.Context, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("%w: expected at most one migration name, got %d", errUsage, len(args))
+		}
+
+		name := "migration"
+		if len(args) == 1 {
+			name = args[0]
+		}
+
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+
+		current, err := directorySql(*dir, options.dialect)
+		if err != nil {
+			return err
+		}
+
+		desired, err := modelsSql(options.dialect, options.initialSql, options.models)
+		if err != nil {
+			return err
+		}
+
+		inspect := inspectOptions{dialect: options.dialect, schemas: schemas, revisionsSchema: options.config.RevisionsSchema}
+		changes, err := diffDevSchemas(ctx, *devURL, inspect, current, xgomigrate.SplitStatements(desired, options.dialect))
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("The migration directory is synced with the models, no changes to be made")
+			return nil
+		}
+
+		filename := fmt.Sprintf("%s_%s.sql", time.Now().UTC().Format("20060102150405"), name)
+		if err := os.WriteFile(filepath.Join(*dir, filename), formatChanges(changes), 0o644); err != nil {
+			return err
+		}
+
+		if err := writeSumFile(*dir); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %s with %d change(s)\n", filepath.Join(*dir, filename), len(changes))
+
+		return nil
+	}
+}
+
+func driftCommand(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error {
+	url := flags.String("url", options.config.URL, "URL of the live database")
+	devURL := flags.String("dev-url", options.config.DevUrl, "URL of an empty dev database, used to compute the schema of the models")
+	var schemas stringsFlag
+	flags.Var(&schemas, "schema", "schema to compare, can be repeated (default: the connected schema)")
+
+	return func(ctx context.Context, args []string) error {
+		desired, err := modelsSql(options.dialect, options.initialSql, options.models)
+		if err != nil {
+			return err
+		}
+
+		inspect := inspectOptions{dialect: options.dialect, schemas: schemas, revisionsSchema: options.config.RevisionsSchema}
+		changes, err := diffLiveSchema(ctx, *url, *devURL, inspect, xgomigrate.SplitStatements(desired, options.dialect))
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("No drift, the database matches the models")
+			return nil
+		}
+
+		fmt.Println("-- Changes to bring the database in line with the models:")
+		os.Stdout.Write(formatChanges(changes))
+
+		return errDrift
+	}
+}
+
+func lintCommand(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error {
+	dir := flags.String("dir", defaultMigrationsDir, "migration directory")
+	latest := flags.Int("latest", 1, "number of latest migrations to lint, 0 for all")
+
+	return func(ctx context.Context, args []string) error {
+		migrations, err := xgomigrate.Load(os.DirFS(*dir))
+		if err != nil {
+			return err
+		}
+
+		if *latest > 0 && len(migrations) > *latest {
+			migrations = migrations[len(migrations)-*latest:]
+		}
+
+		issues := lintMigrations(migrations, options.dialect)
+
+		destructive := 0
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.check == lintDestructive {
+				destructive++
+			}
+		}
+
+		fmt.Printf("\n%d migration(s) linted, %d issue(s) found\n", len(migrations), len(issues))
+
+		if destructive > 0 {
+			return fmt.Errorf("%w in %d statement(s), add `-- atlas:nolint destructive` to the migration to allow them", errLint, destructive)
+		}
+
+		return nil
+	}
+}
+
+func hashCommand(options AtlasOptions, flags *flag.FlagSet) func(ctx context.Context, args []string) error {
+	dir := flags.String("dir", defaultMigrationsDir, "migration directory")
+
+	return func(ctx context.Context, args []string) error {
+		if err := writeSumFile(*dir); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %s\n", filepath.Join(*dir, migrate.HashFileName))
+
+		return nil
+	}
+}
+
+// writeSumFile rebuilds the atlas.sum file of dir, as `atlas migrate hash` does.
+func writeSumFile(path string) error {
+	dir, err := migrate.NewLocalDir(path)
+	if err != nil {
+		return err
+	}
+
+	sum, err := dir.Checksum()
+	if err != nil {
+		return fmt.Errorf("failed to compute the checksum of %s: %w", path, err)
+	}
+
+	return migrate.WriteSumFile(dir, sum)
+}
+
+// directorySql returns the statements of the up migrations of dir, in order.
+func directorySql(dir string, dialect string) ([]string, error) {
+	migrations, err := xgomigrate.Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for _, migration := range migrations {
+		statements = append(statements, xgomigrate.SplitStatements(migration.Up, dialect)...)
+	}
+
+	return statements, nil
+}
+
+func formatChanges(changes []*migrate.Change) []byte {
+	var b strings.Builder
+	for _, change := range changes {
+		if change.Comment != "" {
+			fmt.Fprintf(&b, "-- %s\n", change.Comment)
+		}
+		fmt.Fprintf(&b, "%s;\n", change.Cmd)
+	}
+
+	return []byte(b.String())
+}
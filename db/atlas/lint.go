@@ -0,0 +1,140 @@
+package atlas
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anoaland/xgo/db/migrate"
+)
+
+const (
+	// lintDestructive flags statements dropping data, failing the lint.
+	lintDestructive = "destructive"
+	// lintIncompatible flags renames, breaking the running application.
+	lintIncompatible = "incompatible"
+	// lintDataDependent flags changes which may fail depending on the data.
+	lintDataDependent = "data_dependent"
+)
+
+type lintIssue struct {
+	migration *migrate.Migration
+	statement int
+	check     string
+	message   string
+}
+
+func (i lintIssue) String() string {
+	return fmt.Sprintf("%s_%s: statement %d: [%s] %s", i.migration.Version, i.migration.Description, i.statement, i.check, i.message)
+}
+
+type lintRule struct {
+	check   string
+	pattern *regexp.Regexp
+	message string
+}
+
+var lintRules = []lintRule{
+	{lintDestructive, regexp.MustCompile(`(?i)^DROP\s+TABLE\b`), "drops a table"},
+	{lintDestructive, regexp.MustCompile(`(?i)^DROP\s+(SCHEMA|DATABASE)\b`), "drops a schema"},
+	{lintDestructive, regexp.MustCompile(`(?i)^TRUNCATE\b`), "truncates a table"},
+	{lintDestructive, regexp.MustCompile(`(?i)^DELETE\s+FROM\s+[^\s;]+\s*$`), "deletes every row of a table"},
+	{lintIncompatible, regexp.MustCompile(`(?i)\bRENAME\b|\bsp_rename\b`), "renames a table or a column, breaking the running application"},
+	{lintDataDependent, regexp.MustCompile(`(?i)\bALTER\s+COLUMN\s+\S+\s+(SET\s+DATA\s+)?TYPE\b|\bMODIFY\s+(COLUMN\s+)?\S+\s+\w+`), "changes a column type, may fail or lose precision"},
+	{lintDataDependent, regexp.MustCompile(`(?i)\bSET\s+NOT\s+NULL\b`), "makes a column not null, fails when it holds nulls"},
+	{lintDataDependent, regexp.MustCompile(`(?i)^CREATE\s+UNIQUE\s+INDEX\b|\bADD\s+(CONSTRAINT\s+\S+\s+)?UNIQUE\b`), "adds a unique constraint, fails on duplicated values"},
+}
+
+var alterDrop = regexp.MustCompile(`(?i)\bDROP\s+(COLUMN\s+)?(IF\s+EXISTS\s+)?(\S+)`)
+
+var addNotNullColumn = regexp.MustCompile(`(?i)\bADD\s+(COLUMN\s+)?\S+\s+[^,]*\bNOT\s+NULL\b`)
+
+// dropped keywords of ALTER TABLE which do not drop a column
+var nonColumnDrops = map[string]bool{
+	"CONSTRAINT": true, "INDEX": true, "KEY": true, "FOREIGN": true, "PRIMARY": true,
+	"DEFAULT": true, "NOT": true, "CHECK": true, "IDENTITY": true, "EXPRESSION": true,
+	"PARTITION": true,
+}
+
+var nolintDirective = regexp.MustCompile(`(?im)^--\s*atlas:nolint\b(.*)$`)
+
+// lintMigrations checks the up statements of migrations. A migration can
+// skip checks with `-- atlas:nolint [check...]`, every check when none is given.
+func lintMigrations(migrations []*migrate.Migration, dialect string) []lintIssue {
+	var issues []lintIssue
+
+	for _, migration := range migrations {
+		skipped, skipAll := nolintChecks(migration.Up)
+		if skipAll {
+			continue
+		}
+
+		for index, statement := range migrate.SplitStatements(migration.Up, dialect) {
+			statement = stripComments(statement)
+
+			for _, issue := range lintStatement(statement) {
+				if skipped[issue.check] {
+					continue
+				}
+				issue.migration = migration
+				issue.statement = index + 1
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	return issues
+}
+
+func lintStatement(statement string) []lintIssue {
+	var issues []lintIssue
+
+	for _, rule := range lintRules {
+		if rule.pattern.MatchString(statement) {
+			issues = append(issues, lintIssue{check: rule.check, message: rule.message})
+		}
+	}
+
+	if !regexp.MustCompile(`(?i)^ALTER\s+TABLE\b`).MatchString(statement) {
+		return issues
+	}
+
+	for _, match := range alterDrop.FindAllStringSubmatch(statement, -1) {
+		if match[1] == "" && nonColumnDrops[strings.ToUpper(match[3])] {
+			continue
+		}
+		issues = append(issues, lintIssue{check: lintDestructive, message: fmt.Sprintf("drops column %s", match[3])})
+	}
+
+	if match := addNotNullColumn.FindString(statement); match != "" && !strings.Contains(strings.ToUpper(match), "DEFAULT") {
+		issues = append(issues, lintIssue{check: lintDataDependent, message: "adds a not null column without default, fails on a non empty table"})
+	}
+
+	return issues
+}
+
+func nolintChecks(sql string) (map[string]bool, bool) {
+	skipped := map[string]bool{}
+
+	for _, match := range nolintDirective.FindAllStringSubmatch(sql, -1) {
+		checks := strings.Fields(match[1])
+		if len(checks) == 0 {
+			return nil, true
+		}
+		for _, check := range checks {
+			skipped[check] = true
+		}
+	}
+
+	return skipped, false
+}
+
+// stripComments removes the leading comment lines of a statement.
+func stripComments(statement string) string {
+	lines := strings.Split(statement, "\n")
+	for len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "--") {
+		lines = lines[1:]
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
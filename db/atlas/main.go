@@ -1,7 +1,10 @@
 package atlas
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -52,23 +55,48 @@ import (
 //	  }
 //	  excludes = local.dbenv.revisionsSchema
 //	}
+//
+// Besides `m` and `e`, it provides the `status`, `diff`, `lint`, `drift` and
+// `hash` commands, e.g. `go run ./atlas status`. Run `go run ./atlas help`
+// for their flags.
 func AtlasMain(dialect string, initialSql string, config AtlasConfig, models []interface{}) {
 	// argsWithProg := os.Args
 	argsWithoutProg := os.Args[1:]
 
 	if len(argsWithoutProg) == 0 {
 		fmt.Println("No arguments provided")
+		printUsage(os.Stderr)
 		os.Exit(1)
 	}
 
+	options := AtlasOptions{
+		config:     config,
+		initialSql: initialSql,
+		dialect:    dialect,
+		models:     models,
+	}
+
 	switch argsWithoutProg[0] {
 	case "m":
 		loadModels(dialect, initialSql, models)
 	case "e":
 		loadEnv(config)
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
 	default:
-		fmt.Println("Invalid argument, please use 'm' or 'e'")
-		os.Exit(1)
+		command, ok := commands[argsWithoutProg[0]]
+		if !ok {
+			fmt.Printf("Invalid argument '%s'\n", argsWithoutProg[0])
+			printUsage(os.Stderr)
+			os.Exit(1)
+		}
+
+		if err := command.run(context.Background(), options, argsWithoutProg[1:]); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", command.name, err)
+			}
+			os.Exit(exitCode(err))
+		}
 	}
 
 }
@@ -85,13 +113,13 @@ func AtlasMain(dialect string, initialSql string, config AtlasConfig, models []i
 //	}
 func loadModels(dialect string, sql string, models []interface{}) {
 
-	stmts, err := gormschema.New(dialect).Load(models...)
+	stmts, err := modelsSql(dialect, sql, models)
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load gorm schema: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	_, err = io.WriteString(os.Stdout, sql+stmts)
+	_, err = io.WriteString(os.Stdout, stmts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to write output: %v\n", err)
 		os.Exit(1)
@@ -123,3 +151,14 @@ func loadEnv(config AtlasConfig) {
 		log.Fatal("Can not write atlas config: ", err)
 	}
 }
+
+// modelsSql returns the DDL of models, preceded by initialSql.
+// gormschema records the statements process-wide, it is called once per run.
+func modelsSql(dialect string, initialSql string, models []interface{}) (string, error) {
+	stmts, err := gormschema.New(dialect).Load(models...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load gorm schema: %w", err)
+	}
+
+	return initialSql + stmts, nil
+}
@@ -0,0 +1,117 @@
+package atlas
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	xgomigrate "github.com/anoaland/xgo/db/migrate"
+	"gorm.io/gorm"
+)
+
+const atlasRevisionsTable = "atlas_schema_revisions"
+
+// atlasRevision is a row of the revisions table of the atlas CLI.
+type atlasRevision struct {
+	Version     string
+	Description string
+	Applied     int
+	Total       int
+	ExecutedAt  time.Time
+	Error       string
+}
+
+// migrationStatus is a migration listed by the status command.
+type migrationStatus struct {
+	xgomigrate.Status
+	// Error is the error of a migration partially applied by the atlas CLI.
+	Error string
+}
+
+// atlasStatuses returns the status of the migrations of dir applied by the
+// atlas CLI, read from its atlas_schema_revisions table. The table is looked
+// up in revisionsSchema, or in the schema of the connection and then in the
+// atlas_schema_revisions schema, where atlas creates it by default.
+func atlasStatuses(ctx context.Context, db *gorm.DB, dialect string, dir fs.FS, revisionsSchema string) ([]migrationStatus, error) {
+	migrations, err := xgomigrate.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	db = db.WithContext(ctx)
+
+	tables := []string{atlasRevisionsTable, atlasRevisionsTable + "." + atlasRevisionsTable}
+	if revisionsSchema != "" {
+		tables = []string{revisionsSchema + "." + atlasRevisionsTable}
+	}
+	if dialect == "sqlite" {
+		tables = []string{atlasRevisionsTable}
+	}
+
+	revisions := map[string]atlasRevision{}
+	for _, table := range tables {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+
+		var rows []atlasRevision
+		err := db.Table(table).Select("version", "description", "applied", "total", "executed_at", "error").Find(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to read atlas revisions: %w", err)
+		}
+
+		for _, row := range rows {
+			revisions[trimVersion(row.Version)] = row
+		}
+		break
+	}
+
+	latest := ""
+	for version := range revisions {
+		if latest == "" || xgomigrate.CompareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	var statuses []migrationStatus
+	for _, migration := range migrations {
+		status := migrationStatus{Status: xgomigrate.Status{Version: migration.Version, Description: migration.Description}}
+		if revision, ok := revisions[migration.Version]; ok {
+			status.AppliedAt = revision.ExecutedAt
+			status.Error = revision.Error
+			status.Applied = revision.Error == "" && revision.Applied >= revision.Total
+			delete(revisions, migration.Version)
+		} else {
+			status.OutOfOrder = latest != "" && xgomigrate.CompareVersions(migration.Version, latest) < 0
+		}
+		statuses = append(statuses, status)
+	}
+
+	var missing []migrationStatus
+	for version, revision := range revisions {
+		missing = append(missing, migrationStatus{Status: xgomigrate.Status{
+			Version:     version,
+			Description: revision.Description,
+			Applied:     true,
+			AppliedAt:   revision.ExecutedAt,
+			Missing:     true,
+		}})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return xgomigrate.CompareVersions(missing[i].Version, missing[j].Version) < 0
+	})
+
+	return append(statuses, missing...), nil
+}
+
+// trimVersion drops the leading zeros of a version, as xgomigrate.Load does.
+func trimVersion(version string) string {
+	if trimmed := strings.TrimLeft(version, "0"); trimmed != "" {
+		return trimmed
+	}
+
+	return "0"
+}
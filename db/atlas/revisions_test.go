@@ -0,0 +1,70 @@
+package atlas
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/anoaland/xgo/internal/testdb"
+)
+
+func TestAtlasStatuses(t *testing.T) {
+	db := testdb.New(t)
+
+	dir := fstest.MapFS{
+		"001_init.sql":    {Data: []byte("CREATE TABLE a (id int);")},
+		"002_users.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"003_orders.sql":  {Data: []byte("CREATE TABLE c (id int);")},
+		"004_pending.sql": {Data: []byte("CREATE TABLE d (id int);")},
+		"atlas.sum":       {Data: []byte("h1:...")},
+	}
+
+	statuses, err := atlasStatuses(context.Background(), db, "sqlite", dir, "")
+	if err != nil || len(statuses) != 4 || statuses[0].Applied {
+		t.Fatalf("expected every migration to be pending without revisions table, got %+v (%v)", statuses, err)
+	}
+
+	if err := db.Exec(`CREATE TABLE atlas_schema_revisions (version text PRIMARY KEY, description text, type integer, applied integer, total integer,
+		executed_at datetime, execution_time integer, error text, error_stmt text, hash text, partial_hashes text, operator_version text)`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, revision := range []atlasRevision{
+		{Version: "001", Description: "init", Applied: 1, Total: 1, ExecutedAt: now},
+		{Version: "002", Description: "users", Applied: 1, Total: 1, ExecutedAt: now},
+		{Version: "003", Description: "orders", Applied: 0, Total: 1, ExecutedAt: now, Error: "syntax error"},
+		{Version: "000", Description: "removed", Applied: 1, Total: 1, ExecutedAt: now},
+	} {
+		if err := db.Table(atlasRevisionsTable).Create(&revision).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err = atlasStatuses(context.Background(), db, "sqlite", dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		version          string
+		applied, missing bool
+		error            string
+	}{
+		{"1", true, false, ""},
+		{"2", true, false, ""},
+		{"3", false, false, "syntax error"},
+		{"4", false, false, ""},
+		{"0", true, true, ""},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), statuses)
+	}
+
+	for i, status := range statuses {
+		if status.Version != want[i].version || status.Applied != want[i].applied || status.Missing != want[i].missing || status.Error != want[i].error {
+			t.Fatalf("expected the status %+v, got %+v", want[i], status)
+		}
+	}
+}
@@ -1,13 +1,27 @@
 package atlas
 
+import "github.com/anoaland/xgo/utils"
+
 type AtlasConfig struct {
-	URL             string `json:"url"`
-	DevUrl          string `json:"dev"`
-	RevisionsSchema string `json:"revisionsSchema"`
+	URL             string `json:"url" env:"ATLAS_URL" required:"true"`
+	DevUrl          string `json:"dev" env:"ATLAS_DEV_URL"`
+	RevisionsSchema string `json:"revisionsSchema" env:"ATLAS_REVISIONS_SCHEMA"`
 }
 
 type AtlasOptions struct {
 	config     AtlasConfig
 	initialSql string
 	dialect    string
+	models     []interface{}
+}
+
+// LoadAtlasConfig reads the config from the `ATLAS_*` environment variables,
+// each prefixed with the optional prefix, see utils.LoadEnvConfig.
+func LoadAtlasConfig(prefix ...string) (*AtlasConfig, error) {
+	config := &AtlasConfig{}
+	if err := utils.LoadEnvConfig(config, prefix...); err != nil {
+		return nil, err
+	}
+
+	return config, nil
 }
@@ -0,0 +1,510 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/anoaland/xgo/auth"
+	"github.com/anoaland/xgo/db/replica"
+	"github.com/anoaland/xgo/internal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	pluginName = "xgo:audit"
+	beforeKey  = "xgo:audit:before"
+)
+
+type Action string
+
+const (
+	Create     Action = "create"
+	Update     Action = "update"
+	Delete     Action = "delete"
+	SoftDelete Action = "soft_delete"
+)
+
+// Change is the value of a field before and after a write, nil for a created
+// or deleted row.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Changes are the changed fields of a row, by column.
+type Changes map[string]Change
+
+func (c Changes) Value() (driver.Value, error) {
+	value, err := json.Marshal(c)
+	return string(value), err
+}
+
+func (c *Changes) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(value), c)
+	case []byte:
+		return json.Unmarshal(value, c)
+	}
+
+	return fmt.Errorf("can not scan %T into audit changes", value)
+}
+
+func (Changes) GormDataType() string {
+	return string(schema.String)
+}
+
+// Entry records a write of a row.
+type Entry struct {
+	ID         uint64    `json:"id" gorm:"primaryKey"`
+	Action     Action    `json:"action" gorm:"size:16;not null"`
+	Table      string    `json:"table" gorm:"size:128;not null;index:idx_audit_row"`
+	PrimaryKey string    `json:"primaryKey" gorm:"size:255;not null;index:idx_audit_row"`
+	Actor      string    `json:"actor" gorm:"size:255;index"`
+	RequestID  string    `json:"requestId" gorm:"size:64;index"`
+	Changes    Changes   `json:"changes"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+}
+
+// Sink receives the entries of an audited write. db is a session in the
+// transaction of the write, failing the write when Write fails.
+type Sink interface {
+	Write(db *gorm.DB, entries []Entry) error
+}
+
+// SinkFunc is a Sink function, e.g. sending the entries to a log.
+type SinkFunc func(db *gorm.DB, entries []Entry) error
+
+func (f SinkFunc) Write(db *gorm.DB, entries []Entry) error {
+	return f(db, entries)
+}
+
+// TableSink inserts the entries into a table, see Migrate.
+type TableSink struct {
+	// Table is the name of the audit table.
+	// Optional. Default: "audit_logs".
+	Table string
+}
+
+func (s TableSink) Write(db *gorm.DB, entries []Entry) error {
+	return db.Table(s.table()).Create(&entries).Error
+}
+
+// Migrate creates the audit table, see also Entry for versioned migrations.
+func (s TableSink) Migrate(db *gorm.DB) error {
+	return db.Table(s.table()).AutoMigrate(&Entry{})
+}
+
+func (s TableSink) table() string {
+	if s.Table == "" {
+		return "audit_logs"
+	}
+
+	return s.Table
+}
+
+type Config struct {
+	// Sink receives the entries of every audited write.
+	// Optional. Default: TableSink{}.
+	Sink Sink
+
+	// Actor returns the actor of a write from its context, see gorm.DB.WithContext.
+	// Optional. Default: DefaultActor.
+	Actor func(ctx context.Context) string
+
+	// Exclude lists the fields of all the models which are never recorded, by
+	// Go or column name, e.g. "PasswordHash".
+	// Optional.
+	Exclude []string
+}
+
+// Model opts a model in the audit trail.
+type Model struct {
+	// Value is a value of the model, e.g. &User{}.
+	Value interface{}
+
+	// Exclude lists the fields of the model which are never recorded, by Go or
+	// column name.
+	// Optional.
+	Exclude []string
+}
+
+// Auditor records the creates, updates and deletes of the audited models,
+// through Repository or directly with gorm, with the actor and request id of
+// their context and the changed fields.
+type Auditor struct {
+	config Config
+	// models maps the type of the audited models to their excluded columns
+	models map[reflect.Type]map[string]bool
+}
+
+// Register installs an Auditor of models on db. Only the writes of the
+// registered models are recorded.
+func Register(db *gorm.DB, models []Model, config ...Config) (*Auditor, error) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	if cfg.Sink == nil {
+		cfg.Sink = TableSink{}
+	}
+
+	if cfg.Actor == nil {
+		cfg.Actor = DefaultActor
+	}
+
+	a := &Auditor{config: cfg, models: map[reflect.Type]map[string]bool{}}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model.Value); err != nil {
+			return nil, fmt.Errorf("failed to parse audited model %T: %w", model.Value, err)
+		}
+
+		excluded := map[string]bool{}
+		for _, name := range cfg.Exclude {
+			if field := stmt.Schema.LookUpField(name); field != nil {
+				excluded[field.DBName] = true
+			}
+		}
+		for _, name := range model.Exclude {
+			field := stmt.Schema.LookUpField(name)
+			if field == nil {
+				return nil, fmt.Errorf("unknown excluded field '%s' of %s", name, stmt.Schema.Name)
+			}
+			excluded[field.DBName] = true
+		}
+		a.models[stmt.Schema.ModelType] = excluded
+	}
+
+	if err := db.Use(a); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Auditor) Name() string {
+	return pluginName
+}
+
+func (a *Auditor) Initialize(db *gorm.DB) error {
+	// the entries are written in the transaction of the write, so that a failed
+	// sink rolls the write back
+	callbacks := []error{
+		db.Callback().Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").
+			Register(pluginName+":create", a.afterCreate),
+		db.Callback().Update().After("gorm:begin_transaction").Before("gorm:update").
+			Register(pluginName+":before_update", a.beforeWrite),
+		db.Callback().Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").
+			Register(pluginName+":update", a.afterUpdate),
+		db.Callback().Delete().After("gorm:begin_transaction").Before("gorm:delete").
+			Register(pluginName+":before_delete", a.beforeWrite),
+		db.Callback().Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").
+			Register(pluginName+":delete", a.afterDelete),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DefaultActor returns the name of the user of auth.UserFrom: the result of
+// its String method, the Username of an auth.AppUser, or "" without user.
+func DefaultActor(ctx context.Context) string {
+	switch user := auth.UserFrom(ctx).(type) {
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return user.String()
+	case auth.AppUser:
+		return user.Username
+	case *auth.AppUser:
+		return user.Username
+	default:
+		return fmt.Sprint(user)
+	}
+}
+
+// audited returns the excluded columns of the model of the statement, or
+// false when it is not audited.
+func (a *Auditor) audited(db *gorm.DB) (map[string]bool, bool) {
+	if db.Error != nil || db.Statement.DryRun || db.Statement.Schema == nil {
+		return nil, false
+	}
+
+	excluded, ok := a.models[db.Statement.Schema.ModelType]
+	return excluded, ok
+}
+
+func (a *Auditor) afterCreate(db *gorm.DB) {
+	excluded, ok := a.audited(db)
+	if !ok || db.Statement.RowsAffected == 0 {
+		return
+	}
+
+	var entries []Entry
+	eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		entries = append(entries, a.entry(db, Create, row, diff(db, excluded, reflect.Value{}, row)))
+	})
+
+	a.write(db, entries)
+}
+
+// beforeWrite loads the rows about to be updated or deleted.
+func (a *Auditor) beforeWrite(db *gorm.DB) {
+	if _, ok := a.audited(db); !ok {
+		return
+	}
+
+	conditions := writeConditions(db)
+	if len(conditions) == 0 && !db.AllowGlobalUpdate {
+		// fail closed rather than writing without entries, e.g. when a tenant
+		// scope would turn the write into one of all the rows of the tenant
+		db.AddError(gorm.ErrMissingWhereClause)
+		return
+	}
+
+	rows, err := load(db, db.Statement.Unscoped, conditions)
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: failed to load the rows before the write: %w", err))
+		return
+	}
+
+	db.InstanceSet(beforeKey, rows)
+}
+
+func (a *Auditor) afterUpdate(db *gorm.DB) {
+	a.afterWrite(db, Update)
+}
+
+func (a *Auditor) afterDelete(db *gorm.DB) {
+	if !db.Statement.Unscoped && db.Statement.Schema != nil && softDeletes(db.Statement.Schema) {
+		a.afterWrite(db, SoftDelete)
+		return
+	}
+
+	a.afterWrite(db, Delete)
+}
+
+func (a *Auditor) afterWrite(db *gorm.DB, action Action) {
+	excluded, ok := a.audited(db)
+	before, loaded := db.InstanceGet(beforeKey)
+	if !ok || !loaded || db.Statement.RowsAffected == 0 {
+		return
+	}
+
+	beforeRows := before.(reflect.Value)
+	if beforeRows.Len() == 0 {
+		return
+	}
+
+	afterRows := map[string]reflect.Value{}
+	if action != Delete {
+		rows, err := load(db, true, []clause.Expression{primaryKeyCondition(db, beforeRows)})
+		if err != nil {
+			db.AddError(fmt.Errorf("audit: failed to load the rows after the write: %w", err))
+			return
+		}
+
+		eachRow(rows, func(row reflect.Value) {
+			afterRows[primaryKey(db, row)] = row
+		})
+	}
+
+	var entries []Entry
+	eachRow(beforeRows, func(row reflect.Value) {
+		changes := diff(db, excluded, row, afterRows[primaryKey(db, row)])
+		if len(changes) == 0 && action == Update {
+			return
+		}
+
+		if action == Update && softDeleted(db.Statement.Schema, changes) {
+			entries = append(entries, a.entry(db, SoftDelete, row, changes))
+		} else {
+			entries = append(entries, a.entry(db, action, row, changes))
+		}
+	})
+
+	a.write(db, entries)
+}
+
+func (a *Auditor) entry(db *gorm.DB, action Action, row reflect.Value, changes Changes) Entry {
+	ctx := db.Statement.Context
+
+	return Entry{
+		Action:     action,
+		Table:      db.Statement.Table,
+		PrimaryKey: primaryKey(db, row),
+		Actor:      a.config.Actor(ctx),
+		RequestID:  internal.RequestIDFromContext(ctx),
+		Changes:    changes,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+func (a *Auditor) write(db *gorm.DB, entries []Entry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	session := db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+	if err := a.config.Sink.Write(session, entries); err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+	}
+}
+
+// writeConditions returns the conditions of an update or a delete, including
+// the primary key of its model which gorm adds when building the statement.
+func writeConditions(db *gorm.DB) []clause.Expression {
+	var conditions []clause.Expression
+	if where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conditions = append(conditions, where.Exprs...)
+	}
+
+	if db.Statement.ReflectValue.IsValid() {
+		if condition := primaryKeyCondition(db, db.Statement.ReflectValue); condition != nil {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	return conditions
+}
+
+func primaryKeyCondition(db *gorm.DB, rows reflect.Value) clause.Expression {
+	s := db.Statement.Schema
+	_, values := schema.GetIdentityFieldValuesMap(db.Statement.Context, rows, s.PrimaryFields)
+	column, queryValues := schema.ToQueryValues(clause.CurrentTable, s.PrimaryFieldDBNames, values)
+	if len(queryValues) == 0 {
+		return nil
+	}
+
+	return clause.IN{Column: column, Values: queryValues}
+}
+
+// load returns the slice of the rows matching conditions, read from the
+// primary in the transaction of the write.
+func load(db *gorm.DB, unscoped bool, conditions []clause.Expression) (reflect.Value, error) {
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+
+	tx := replica.UsePrimary(db.Session(&gorm.Session{NewDB: true, SkipHooks: true})).Table(db.Statement.Table)
+	if unscoped {
+		tx = tx.Unscoped()
+	}
+
+	if len(conditions) > 0 {
+		tx = tx.Clauses(clause.Where{Exprs: conditions})
+	}
+
+	if err := tx.Find(rows.Interface()).Error; err != nil {
+		return reflect.Value{}, err
+	}
+
+	return rows.Elem(), nil
+}
+
+func eachRow(value reflect.Value, fn func(row reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if row := reflect.Indirect(value.Index(i)); row.Kind() == reflect.Struct {
+				fn(row)
+			}
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+func primaryKey(db *gorm.DB, row reflect.Value) string {
+	var values []string
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		value, _ := field.ValueOf(db.Statement.Context, row)
+		values = append(values, fmt.Sprint(value))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// diff returns the changed fields between two rows, an invalid row standing
+// for a row which does not exist.
+func diff(db *gorm.DB, excluded map[string]bool, before reflect.Value, after reflect.Value) Changes {
+	changes := Changes{}
+	for _, field := range db.Statement.Schema.Fields {
+		if field.DBName == "" || excluded[field.DBName] {
+			continue
+		}
+
+		var change Change
+		if before.IsValid() {
+			change.Before = fieldValue(db, field, before)
+		}
+		if after.IsValid() {
+			change.After = fieldValue(db, field, after)
+		}
+
+		beforeJson, errBefore := json.Marshal(change.Before)
+		afterJson, errAfter := json.Marshal(change.After)
+		if errBefore == nil && errAfter == nil && string(beforeJson) == string(afterJson) {
+			continue
+		}
+
+		changes[field.DBName] = change
+	}
+
+	return changes
+}
+
+func fieldValue(db *gorm.DB, field *schema.Field, row reflect.Value) interface{} {
+	value, _ := field.ValueOf(db.Statement.Context, row)
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		return rv.Elem().Interface()
+	}
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			return value
+		}
+	}
+
+	return value
+}
+
+// softDeletes reports whether the model is soft deleted, e.g. with a
+// gorm.DeletedAt field.
+func softDeletes(s *schema.Schema) bool {
+	return len(s.DeleteClauses) > 0
+}
+
+// softDeleted reports whether an update sets the DeletedAt field of a row,
+// e.g. a time.Time field set by Repository.SoftDelete.
+func softDeleted(s *schema.Schema, changes Changes) bool {
+	field := s.LookUpField("DeletedAt")
+	if field == nil {
+		return false
+	}
+
+	change, ok := changes[field.DBName]
+	return ok && isZero(change.Before) && !isZero(change.After)
+}
+
+func isZero(value interface{}) bool {
+	return value == nil || reflect.ValueOf(value).IsZero()
+}
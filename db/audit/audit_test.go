@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anoaland/xgo/auth"
+	"github.com/anoaland/xgo/internal"
+	"github.com/anoaland/xgo/internal/testdb"
+	"github.com/anoaland/xgo/repository"
+	"github.com/anoaland/xgo/tenant"
+	"gorm.io/gorm"
+)
+
+type account struct {
+	ID           uint
+	TenantID     string
+	Name         string
+	Active       bool
+	PasswordHash string
+	DeletedAt    *time.Time
+}
+
+type accountDto struct {
+	ID     uint
+	Name   string
+	Active bool
+}
+
+func (d accountDto) ToModel() *account {
+	return &account{ID: d.ID, Name: d.Name, Active: d.Active, PasswordHash: "hash"}
+}
+
+func (accountDto) FromModel(m *account) *accountDto {
+	return &accountDto{ID: m.ID, Name: m.Name, Active: m.Active}
+}
+
+type unaudited struct {
+	ID   uint
+	Name string
+}
+
+var requestCtx = tenant.WithTenant(auth.WithUser(
+	context.WithValue(context.Background(), internal.RequestIDContextKey, "request-1"),
+	auth.AppUser{Username: "alice"},
+), "a")
+
+func newTestDB(t *testing.T, config ...Config) *gorm.DB {
+	t.Helper()
+
+	db := testdb.New(t, &account{}, &unaudited{})
+
+	if err := (TableSink{}).Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Register(db, []Model{{Value: &account{}, Exclude: []string{"PasswordHash"}}}, config...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tenant.Register(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func repo(db *gorm.DB) *repository.BriefRepository[account, accountDto, accountDto] {
+	return repository.Brief[account, accountDto, accountDto](db).WithContext(requestCtx)
+}
+
+func entries(t *testing.T, db *gorm.DB) []Entry {
+	t.Helper()
+
+	var entries []Entry
+	if err := db.Table("audit_logs").Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func TestAuditTrail(t *testing.T) {
+	db := newTestDB(t)
+	r := repo(db)
+
+	created, err := r.Create(accountDto{Name: "a", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Update(accountDto{Name: "b"}, "id = ?", created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Patch(repository.Patch{"active": false}, "id = ?", created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.SoftDelete("id = ?", created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Delete("id = ?", created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(&unaudited{Name: "ignored"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got := entries(t, db)
+	want := []Action{Create, Update, Update, SoftDelete, Delete}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), got)
+	}
+
+	for i, entry := range got {
+		if entry.Action != want[i] || entry.Table != "accounts" || entry.Actor != "alice" || entry.RequestID != "request-1" {
+			t.Fatalf("unexpected entry %d: %+v", i, entry)
+		}
+
+		if _, ok := entry.Changes["password_hash"]; ok {
+			t.Fatalf("expected the excluded field not to be recorded, got %+v", entry.Changes)
+		}
+	}
+
+	if got[0].Changes["name"].After != "a" || got[0].Changes["name"].Before != nil {
+		t.Fatalf("expected the created name, got %+v", got[0].Changes)
+	}
+
+	if changes := got[1].Changes; len(changes) != 1 || changes["name"].Before != "a" || changes["name"].After != "b" {
+		t.Fatalf("expected the updated name only, got %+v", changes)
+	}
+
+	if changes := got[2].Changes; len(changes) != 1 || changes["active"].Before != true || changes["active"].After != false {
+		t.Fatalf("expected the patched active field only, got %+v", changes)
+	}
+
+	if change := got[3].Changes["deleted_at"]; change.Before != nil || change.After == nil {
+		t.Fatalf("expected the deletion time, got %+v", got[3].Changes)
+	}
+
+	if change := got[4].Changes["name"]; change.Before != "b" || change.After != nil {
+		t.Fatalf("expected the deleted name, got %+v", got[4].Changes)
+	}
+}
+
+func TestFailingSinkRollsBack(t *testing.T) {
+	sink := SinkFunc(func(db *gorm.DB, entries []Entry) error {
+		return errors.New("sink unavailable")
+	})
+	db := newTestDB(t, Config{Sink: sink})
+
+	if _, err := repo(db).Create(accountDto{Name: "a"}); err == nil {
+		t.Fatal("expected the create to fail")
+	}
+
+	var count int64
+	if err := tenant.Bypass(db).Model(&account{}).Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("expected the create to be rolled back, got %d rows (%v)", count, err)
+	}
+}
+
+func TestWritesWithoutConditionsAreAudited(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := repo(db).Create(accountDto{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.WithContext(requestCtx).Model(&account{}).Update("name", "x").Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected ErrMissingWhereClause, got %v", err)
+	}
+
+	global := db.WithContext(requestCtx).Session(&gorm.Session{AllowGlobalUpdate: true})
+	if err := global.Model(&account{}).Update("name", "all").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got := entries(t, db)
+	if len(got) != 2 || got[1].Action != Update || got[1].Changes["name"].After != "all" {
+		t.Fatalf("expected the global update to be audited, got %+v", got)
+	}
+}
+
+func TestBypassedWritesAreAudited(t *testing.T) {
+	db := newTestDB(t)
+
+	created, err := repo(db).Create(accountDto{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tenant.Bypass(db).Model(&account{}).Where("id = ?", created.ID).Update("name", "y").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got := entries(t, db)
+	if len(got) != 2 || got[1].Action != Update || got[1].Changes["name"].Before != "a" || got[1].Changes["name"].After != "y" {
+		t.Fatalf("expected the bypassed update to be audited, got %+v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package connect
+
+import (
+	"context"
+	"time"
+
+	"github.com/anoaland/xgo/db/logger"
+	"gorm.io/gorm"
+)
+
+// Open opens a database with gorm, pings it, and retries according to the
+// Retry option found in opts. Each failed attempt is logged through the GORM
+// logger of opts. Authentication and missing database errors are not retried.
+//
+// dialector is called for every attempt, target describes the database in
+// logs and errors, and classify recognises the driver specific errors.
+func Open(ctx context.Context, target string, dialector func() gorm.Dialector, classify Classifier, opts ...gorm.Option) (*gorm.DB, error) {
+	retry := RetryOptions{}.withDefaults()
+	gormOpts := make([]gorm.Option, 0, len(opts)+1)
+	for _, opt := range opts {
+		if r, ok := opt.(*retryOption); ok {
+			retry = r.options
+			continue
+		}
+		gormOpts = append(gormOpts, opt)
+	}
+	gormOpts = append(gormOpts, disablePingOption{})
+
+	log := logger.LogFromOpts(opts...)
+
+	var lastErr error
+	var kind error
+	for attempt := 1; attempt <= retry.MaxAttempts; attempt++ {
+		db, err := openAndPing(ctx, dialector(), retry.PingTimeout, gormOpts)
+		if err == nil {
+			if attempt > 1 {
+				log.Info(ctx, "Connected to %s after %d attempts", target, attempt)
+			}
+			return db, nil
+		}
+
+		lastErr = err
+		kind = Classify(err, classify)
+		log.Warn(ctx, "Attempt %d/%d to connect %s failed: %v", attempt, retry.MaxAttempts, target, err)
+
+		if isPermanent(kind) || attempt == retry.MaxAttempts {
+			return nil, &ConnectError{Kind: kind, Target: target, Attempts: attempt, Err: lastErr}
+		}
+
+		timer := time.NewTimer(retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &ConnectError{Kind: kind, Target: target, Attempts: attempt, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+
+	return nil, &ConnectError{Kind: kind, Target: target, Attempts: retry.MaxAttempts, Err: lastErr}
+}
+
+func openAndPing(ctx context.Context, dialector gorm.Dialector, pingTimeout time.Duration, opts []gorm.Option) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, opts...)
+	if err != nil {
+		Close(db)
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := sqlDB.PingContext(pingCtx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Close closes the connection pool of db, e.g. when configuring a connection
+// returned by Open fails. A nil db is ignored.
+func Close(db *gorm.DB) {
+	if db == nil || db.ConnPool == nil {
+		return
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
@@ -0,0 +1,73 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+var (
+	// ErrAuthentication is returned when the server rejects the credentials.
+	// It is never retried.
+	ErrAuthentication = errors.New("database authentication failed")
+	// ErrDatabaseNotFound is returned when the database does not exist.
+	// It is never retried.
+	ErrDatabaseNotFound = errors.New("database not found")
+	// ErrNetwork is returned when the server can not be reached.
+	ErrNetwork = errors.New("database network error")
+	// ErrUnknown is returned for any other failure.
+	ErrUnknown = errors.New("database connection failed")
+)
+
+// ConnectError describes a failed connection. Use errors.Is with
+// ErrAuthentication, ErrDatabaseNotFound, ErrNetwork or ErrUnknown to tell
+// the failures apart.
+type ConnectError struct {
+	// Kind is one of the Err* sentinel errors of this package.
+	Kind error
+	// Target describes the database, e.g. "database 'app' on 'localhost'".
+	Target   string
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("%s: failed to connect %s after %d attempt(s): %v", e.Kind, e.Target, e.Attempts, e.Err)
+}
+
+func (e *ConnectError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Classifier maps a driver specific error to ErrAuthentication or
+// ErrDatabaseNotFound. It returns nil when it does not recognise err.
+type Classifier func(err error) error
+
+// Classify returns the kind of err, consulting the driver classifier first.
+func Classify(err error, classify Classifier) error {
+	if classify != nil {
+		if kind := classify(err); kind != nil {
+			return kind
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return ErrNetwork
+	}
+
+	return ErrUnknown
+}
+
+func isPermanent(kind error) bool {
+	return kind == ErrAuthentication || kind == ErrDatabaseNotFound
+}
@@ -0,0 +1,113 @@
+package connect
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RetryOptions struct {
+	// MaxAttempts is the number of connection attempts, including the first one.
+	// Optional. Default: 1, no retry.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the second attempt.
+	// Optional. Default: 500ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts.
+	// Optional. Default: 30s.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the wait after every attempt.
+	// Optional. Default: 2.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction, between 0 and 1.
+	// Optional. Default: 0.2.
+	Jitter float64
+
+	// PingTimeout limits the ping checking every attempt.
+	// Optional. Default: 5s.
+	PingTimeout time.Duration
+}
+
+// Retry returns a gorm.Option configuring the retries of ConnectContext.
+// It can be passed along the *gorm.Config:
+//
+//	db, err := database.ConnectContext(ctx, config, &gorm.Config{}, connect.Retry(connect.RetryOptions{
+//		MaxAttempts: 10,
+//	}))
+func Retry(options RetryOptions) gorm.Option {
+	return &retryOption{options: options.withDefaults()}
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 1
+	}
+
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 500 * time.Millisecond
+	}
+
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+
+	if o.Multiplier < 1 {
+		o.Multiplier = 2
+	}
+
+	if o.Jitter <= 0 || o.Jitter > 1 {
+		o.Jitter = 0.2
+	}
+
+	if o.PingTimeout <= 0 {
+		o.PingTimeout = 5 * time.Second
+	}
+
+	return o
+}
+
+// Backoff returns the wait after the given failed attempt, starting at 1.
+func (o RetryOptions) Backoff(attempt int) time.Duration {
+	o = o.withDefaults()
+
+	backoff := float64(o.InitialBackoff) * math.Pow(o.Multiplier, float64(attempt-1))
+	if backoff > float64(o.MaxBackoff) {
+		backoff = float64(o.MaxBackoff)
+	}
+
+	jitter := backoff * o.Jitter * (rand.Float64()*2 - 1)
+
+	return time.Duration(backoff + jitter)
+}
+
+// retryOption is a gorm.Option carrying the RetryOptions, ignored by gorm.
+type retryOption struct {
+	options RetryOptions
+}
+
+func (o *retryOption) Apply(*gorm.Config) error {
+	return nil
+}
+
+func (o *retryOption) AfterInitialize(*gorm.DB) error {
+	return nil
+}
+
+// disablePingOption disables the ping of gorm.Open, replaced by a ping
+// bounded by RetryOptions.PingTimeout.
+type disablePingOption struct{}
+
+func (disablePingOption) Apply(config *gorm.Config) error {
+	config.DisableAutomaticPing = true
+	return nil
+}
+
+func (disablePingOption) AfterInitialize(*gorm.DB) error {
+	return nil
+}
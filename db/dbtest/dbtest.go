@@ -0,0 +1,196 @@
+package dbtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/anoaland/xgo/db/atlas"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var silentLogger = gormlogger.Default.LogMode(gormlogger.Silent)
+
+type Options struct {
+	// Migrations is an atlas migration directory applied with
+	// `atlas migrate apply`, the atlas CLI must be installed.
+	// Optional.
+	Migrations fs.FS
+	// RevisionsSchema is the schema of the atlas revisions table.
+	// Optional.
+	RevisionsSchema string
+	// Models are migrated with AutoMigrate, after Migrations.
+	// Optional.
+	Models []interface{}
+	// Migrate is called last, e.g. to seed the database.
+	// Optional.
+	Migrate func(db *gorm.DB) error
+
+	// Config of the connection to the test database.
+	// Optional. Default: a silent logger.
+	Config *gorm.Config
+	// Prefix of the database names, the rest of the name is unique.
+	// Optional. Default: "xgotest".
+	Prefix string
+	// Keep skips dropping the database, to inspect it after a failure.
+	Keep bool
+}
+
+// Database is a uniquely named throwaway database.
+type Database struct {
+	Name string
+	DB   *gorm.DB
+
+	server Server
+	keep   bool
+}
+
+// Create creates and migrates a throwaway database, e.g. shared by the tests
+// of a package from TestMain. Close drops it.
+func Create(ctx context.Context, server Server, opts ...Options) (*Database, error) {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	prefix := options.Prefix
+	if prefix == "" {
+		prefix = "xgotest"
+	}
+
+	name, err := uniqueName(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.CreateDatabase(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to create test database '%s': %w", name, err)
+	}
+
+	config := options.Config
+	if config == nil {
+		config = &gorm.Config{Logger: silentLogger}
+	}
+
+	db, err := server.Connect(ctx, name, config)
+	if err != nil {
+		server.DropDatabase(ctx, name)
+		return nil, err
+	}
+
+	database := &Database{Name: name, DB: db, server: server, keep: options.Keep}
+
+	if err := database.migrate(ctx, options); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to migrate test database '%s': %w", name, err)
+	}
+
+	return database, nil
+}
+
+// New creates and migrates a throwaway database for the test t, dropped when
+// the test and its subtests complete. It fails the test on error.
+func New(t testing.TB, server Server, opts ...Options) *Database {
+	t.Helper()
+
+	database, err := Create(context.Background(), server, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := database.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return database
+}
+
+// Tx begins a transaction rolled back when the test t completes, so every
+// test of a shared database starts from the migrated state.
+func (d *Database) Tx(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	tx := d.DB.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+
+	return tx
+}
+
+// Close closes the connection and drops the database, unless Options.Keep is set.
+func (d *Database) Close() error {
+	if sqlDB, err := d.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	if d.keep {
+		return nil
+	}
+
+	if err := d.server.DropDatabase(context.Background(), d.Name); err != nil {
+		return fmt.Errorf("failed to drop test database '%s': %w", d.Name, err)
+	}
+
+	return nil
+}
+
+func (d *Database) migrate(ctx context.Context, options Options) error {
+	if options.Migrations != nil {
+		_, err := atlas.ApplyMigrationsContext(ctx, atlas.AtlasConfig{
+			URL:             d.server.URL(d.Name),
+			RevisionsSchema: options.RevisionsSchema,
+		}, options.Migrations)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(options.Models) > 0 {
+		if err := d.DB.WithContext(ctx).AutoMigrate(options.Models...); err != nil {
+			return err
+		}
+	}
+
+	if options.Migrate != nil {
+		return options.Migrate(d.DB.WithContext(ctx))
+	}
+
+	return nil
+}
+
+// uniqueName returns a lowercase name, valid unquoted for every dialect and
+// within the 63 characters limit of Postgres identifiers.
+func uniqueName(prefix string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	prefix = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, prefix)
+
+	if len(prefix) > 46 {
+		prefix = prefix[:46]
+	}
+
+	return prefix + "_" + hex.EncodeToString(random), nil
+}
@@ -0,0 +1,112 @@
+package dbtest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+func exists(t *testing.T, path string) bool {
+	t.Helper()
+
+	_, err := os.Stat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+
+	return err == nil
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	server := Sqlite(dir)
+
+	var path string
+	t.Run("created", func(t *testing.T) {
+		database := New(t, server, Options{Models: []interface{}{&item{}}, Prefix: "New"})
+		path = filepath.Join(dir, database.Name+".db")
+
+		if !strings.HasPrefix(database.Name, "new_") || !exists(t, path) {
+			t.Fatalf("expected the database %s to be created in %s", database.Name, dir)
+		}
+
+		if !database.DB.Migrator().HasTable(&item{}) {
+			t.Fatal("expected the models to be migrated")
+		}
+	})
+
+	if exists(t, path) {
+		t.Fatalf("expected the database %s to be dropped on cleanup", path)
+	}
+
+	t.Run("kept", func(t *testing.T) {
+		database := New(t, server, Options{Keep: true})
+		path = filepath.Join(dir, database.Name+".db")
+	})
+
+	if !exists(t, path) {
+		t.Fatalf("expected the database %s to be kept", path)
+	}
+}
+
+func TestTx(t *testing.T) {
+	database := New(t, Sqlite(t.TempDir()), Options{Models: []interface{}{&item{}}})
+
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			tx := database.Tx(t)
+			if err := tx.Create(&item{Name: name}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var count int64
+			if err := tx.Model(&item{}).Count(&count).Error; err != nil || count != 1 {
+				t.Fatalf("expected only the row of this test, got %d (%v)", count, err)
+			}
+		})
+	}
+
+	var count int64
+	if err := database.DB.Model(&item{}).Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("expected the rows to be rolled back, got %d (%v)", count, err)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   string
+	}{
+		{"lowercase", "xgotest", "xgotest"},
+		{"uppercase", "MyTests", "mytests"},
+		{"invalid characters", "my tests-1.é", "my_tests_1__"},
+		{"truncated", strings.Repeat("a", 100), strings.Repeat("a", 46)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, err := uniqueName(test.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !regexp.MustCompile(`^`+test.want+`_[0-9a-f]{16}$`).MatchString(name) || len(name) > 63 {
+				t.Fatalf("expected %s followed by a random suffix, within 63 characters, got %s", test.want, name)
+			}
+
+			other, _ := uniqueName(test.prefix)
+			if other == name {
+				t.Fatalf("expected unique names, got %s twice", name)
+			}
+		})
+	}
+}
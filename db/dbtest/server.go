@@ -0,0 +1,221 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	mysql "github.com/anoaland/xgo/db/mysql"
+	postgres "github.com/anoaland/xgo/db/postgres"
+	sqlite "github.com/anoaland/xgo/db/sqlite"
+	sqlserver "github.com/anoaland/xgo/db/sqlserver"
+	gormmysql "gorm.io/driver/mysql"
+	gormpostgres "gorm.io/driver/postgres"
+	gormsqlserver "gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+// Server creates, connects to and drops the throwaway databases of a
+// database server. Use Postgres, SqlServer, Mysql or Sqlite.
+type Server interface {
+	// Dialect is the gorm dialect name, e.g. "postgres".
+	Dialect() string
+	CreateDatabase(ctx context.Context, name string) error
+	DropDatabase(ctx context.Context, name string) error
+	Connect(ctx context.Context, name string, opts ...gorm.Option) (*gorm.DB, error)
+	// URL of the database for the atlas CLI.
+	URL(name string) string
+}
+
+// Postgres returns a Server creating databases next to the database of config,
+// through a connection to the "postgres" maintenance database, as a connection
+// without database would use the database named after the user.
+func Postgres(config *postgres.PgDatabaseConfig) Server {
+	return &pgServer{config: *config}
+}
+
+// SqlServer returns a Server creating databases next to the database of
+// config, through its DsnWithoutDB connection to the default database of the
+// login, usually master.
+func SqlServer(config *sqlserver.SqlServerDatabaseConfig) Server {
+	return &sqlServer{config: *config}
+}
+
+// Mysql returns a Server creating databases next to the database of config,
+// through its DsnWithoutDB connection, which selects no database.
+func Mysql(config *mysql.MysqlDatabaseConfig) Server {
+	return &mysqlServer{config: *config}
+}
+
+// Sqlite returns a Server creating database files in dir.
+// An empty dir uses os.TempDir.
+func Sqlite(dir string) Server {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	return &sqliteServer{dir: dir}
+}
+
+// pgMaintenanceDB is the database every Postgres server has, to connect to
+// when creating or dropping another one.
+const pgMaintenanceDB = "postgres"
+
+type pgServer struct {
+	config postgres.PgDatabaseConfig
+}
+
+func (s *pgServer) Dialect() string {
+	return "postgres"
+}
+
+func (s *pgServer) CreateDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormpostgres.Open(s.config.DsnWithDB(pgMaintenanceDB)),
+		fmt.Sprintf(`CREATE DATABASE "%s"`, name))
+}
+
+func (s *pgServer) DropDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormpostgres.Open(s.config.DsnWithDB(pgMaintenanceDB)),
+		fmt.Sprintf(`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = '%s' AND pid <> pg_backend_pid()`, name),
+		fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, name))
+}
+
+func (s *pgServer) Connect(ctx context.Context, name string, opts ...gorm.Option) (*gorm.DB, error) {
+	config := s.config
+	config.Name = name
+
+	return postgres.ConnectContext(ctx, &config, opts...)
+}
+
+func (s *pgServer) URL(name string) string {
+	return s.config.DsnWithDB(name)
+}
+
+type sqlServer struct {
+	config sqlserver.SqlServerDatabaseConfig
+}
+
+func (s *sqlServer) Dialect() string {
+	return "sqlserver"
+}
+
+func (s *sqlServer) CreateDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormsqlserver.Open(s.config.DsnWithoutDB()),
+		fmt.Sprintf(`CREATE DATABASE [%s]`, name))
+}
+
+func (s *sqlServer) DropDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormsqlserver.Open(s.config.DsnWithoutDB()),
+		fmt.Sprintf(`IF DB_ID('%[1]s') IS NOT NULL
+BEGIN
+	ALTER DATABASE [%[1]s] SET SINGLE_USER WITH ROLLBACK IMMEDIATE;
+	DROP DATABASE [%[1]s];
+END`, name))
+}
+
+func (s *sqlServer) Connect(ctx context.Context, name string, opts ...gorm.Option) (*gorm.DB, error) {
+	config := s.config
+	config.Name = name
+
+	return sqlserver.ConnectContext(ctx, &config, opts...)
+}
+
+func (s *sqlServer) URL(name string) string {
+	return s.config.Dsn(&name)
+}
+
+type mysqlServer struct {
+	config mysql.MysqlDatabaseConfig
+}
+
+func (s *mysqlServer) Dialect() string {
+	return "mysql"
+}
+
+func (s *mysqlServer) CreateDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormmysql.Open(s.config.DsnWithoutDB()),
+		fmt.Sprintf("CREATE DATABASE `%s`", name))
+}
+
+func (s *mysqlServer) DropDatabase(ctx context.Context, name string) error {
+	return execAdmin(ctx, gormmysql.Open(s.config.DsnWithoutDB()),
+		fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", name))
+}
+
+func (s *mysqlServer) Connect(ctx context.Context, name string, opts ...gorm.Option) (*gorm.DB, error) {
+	config := s.config
+	config.Name = name
+
+	return mysql.ConnectContext(ctx, &config, opts...)
+}
+
+func (s *mysqlServer) URL(name string) string {
+	dsn := url.URL{
+		Scheme: "mysql",
+		User:   url.UserPassword(s.config.User, s.config.Password),
+		Host:   net.JoinHostPort(s.config.Host, s.config.Port),
+		Path:   "/" + name,
+	}
+
+	return dsn.String()
+}
+
+type sqliteServer struct {
+	dir string
+}
+
+func (s *sqliteServer) Dialect() string {
+	return "sqlite"
+}
+
+func (s *sqliteServer) path(name string) string {
+	return filepath.Join(s.dir, name+".db")
+}
+
+// CreateDatabase only creates the directory, SQLite creates the file on connect.
+func (s *sqliteServer) CreateDatabase(ctx context.Context, name string) error {
+	return os.MkdirAll(s.dir, 0o755)
+}
+
+func (s *sqliteServer) DropDatabase(ctx context.Context, name string) error {
+	var errs []error
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(s.path(name) + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *sqliteServer) Connect(ctx context.Context, name string, opts ...gorm.Option) (*gorm.DB, error) {
+	return sqlite.ConnectContext(ctx, &sqlite.SqliteDatabaseConfig{Path: s.path(name)}, opts...)
+}
+
+func (s *sqliteServer) URL(name string) string {
+	return "sqlite://" + s.path(name)
+}
+
+// execAdmin runs the statements on a short-lived connection to the server.
+func execAdmin(ctx context.Context, dialector gorm.Dialector, statements ...string) error {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: silentLogger})
+	if err != nil {
+		return err
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	for _, statement := range statements {
+		if err := db.WithContext(ctx).Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,11 +2,13 @@ package logger
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/anoaland/xgo/internal"
-	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 
 	gormlogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/utils"
@@ -17,6 +19,21 @@ type ZerologGormLogger struct {
 	logger   zerolog.Logger
 	config   gormlogger.Config
 	LogLevel gormlogger.LogLevel
+	// NPlusOneThreshold warns when the same normalized statement runs more than
+	// this many times within one request, a likely N+1 query. 0 disables it.
+	NPlusOneThreshold int
+	// Sql controls parameterization, redaction, truncation and fingerprinting
+	// of the logged statements.
+	Sql SqlLogConfig
+
+	// bound values captured by ParamsFilter while a parameterized statement is
+	// being traced, shared by the copies made by LogMode
+	capture *varsCapture
+}
+
+type varsCapture struct {
+	mu   sync.Mutex
+	vars []interface{}
 }
 
 func NewZerologGormLogger(logger *zerolog.Logger, configs ...gormlogger.Config) *ZerologGormLogger {
@@ -32,10 +49,20 @@ func NewZerologGormLogger(logger *zerolog.Logger, configs ...gormlogger.Config)
 		}
 	}
 
+	if config.LogLevel == 0 {
+		config.LogLevel = gormlogger.Info
+	}
+
 	return &ZerologGormLogger{
-		logger:   *logger,
-		config:   config,
-		LogLevel: config.LogLevel,
+		logger:            *logger,
+		config:            config,
+		LogLevel:          config.LogLevel,
+		NPlusOneThreshold: 10,
+		Sql: SqlLogConfig{
+			Parameterized:  config.ParameterizedQueries,
+			RedactPatterns: DefaultRedactPatterns,
+		},
+		capture: &varsCapture{},
 	}
 }
 
@@ -49,70 +76,144 @@ func (l *ZerologGormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interf
 // Info logs general info messages
 func (l *ZerologGormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= gormlogger.Info {
-		l.logger.Info().Msgf(msg, data...)
+		l.loggerFrom(ctx).Info().Msgf(msg, data...)
 	}
 }
 
 // Warn logs warning messages
 func (l *ZerologGormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= gormlogger.Warn {
-		l.logger.Warn().Msgf(msg, data...)
+		l.loggerFrom(ctx).Warn().Msgf(msg, data...)
 	}
 }
 
 // Error logs error messages
 func (l *ZerologGormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
 	if l.LogLevel >= gormlogger.Error {
-		l.logger.Error().Msgf(msg, data...)
+		l.loggerFrom(ctx).Error().Msgf(msg, data...)
+	}
+}
+
+// ParamsFilter is called by GORM with the statement and its bound values
+// before they are interpolated for Trace. It redacts the configured columns
+// and, in parameterized mode, keeps the values out of the statement.
+func (l *ZerologGormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	params = l.Sql.redact(sql, params)
+
+	if l.isParameterized() {
+		l.capture.vars = params
+		return sql, nil
 	}
+
+	return sql, params
+}
+
+func (l *ZerologGormLogger) isParameterized() bool {
+	return (l.Sql.Parameterized || l.config.ParameterizedQueries) && l.capture != nil
 }
 
 // Trace logs SQL statements with duration
 func (l *ZerologGormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
 	latency := time.Since(begin)
+	stats := internal.RequestStatsFromContext(ctx)
+
+	isError := err != nil && !(l.config.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound))
+	isSlow := l.config.SlowThreshold != 0 && latency > l.config.SlowThreshold
+
+	shouldLog := l.LogLevel > gormlogger.Silent &&
+		((isError && l.LogLevel >= gormlogger.Error) ||
+			(isSlow && l.LogLevel >= gormlogger.Warn) ||
+			l.LogLevel >= gormlogger.Info)
+
+	if !shouldLog && stats == nil {
+		return
+	}
+
+	sql, rows, vars := l.evaluate(fc)
+
+	if shouldLog {
+		// Use the per-request logger carried by the context if available
+		// This ensures SQL logs include the same request_id as application logs
+		logger := l.loggerFrom(ctx)
+
+		msg := "SQL query"
+		event := logger.Info()
+		if isError {
+			event = logger.Error().Err(err)
+		} else if isSlow {
+			msg = "Slow query"
+			event = logger.Warn()
+		}
+
+		arr := zerolog.Arr()
+		arr.Str(utils.FileWithLineNum())
+
+		event = event.Str("sql", l.Sql.truncate(sql))
+		if vars != nil {
+			event = event.Interface("vars", vars)
+		}
+		if l.Sql.Fingerprint {
+			event = event.Str("fingerprint", Fingerprint(sql))
+		}
+
+		event.Int64("rows", rows).
+			Str("latency", latency.String()).
+			Array("stack", arr).
+			Msg(msg)
+	}
+
+	l.recordRequestStats(ctx, stats, sql, latency)
+}
+
+// evaluate calls fc, collecting the bound values passed to ParamsFilter in
+// parameterized mode. The capture is serialized since the logger is shared by
+// every session of the gorm.DB.
+func (l *ZerologGormLogger) evaluate(fc func() (string, int64)) (string, int64, []interface{}) {
+	if !l.isParameterized() {
+		sql, rows := fc()
+		return sql, rows, nil
+	}
+
+	l.capture.mu.Lock()
+	defer l.capture.mu.Unlock()
+
+	l.capture.vars = nil
 	sql, rows := fc()
+	vars := l.capture.vars
+	l.capture.vars = nil
 
-	msg := "SQL query"
-	event := l.logger.Info()
-	if err != nil {
-		event = l.logger.Error().Err(err)
-	} else if latency > l.config.SlowThreshold {
-		msg = "Slow query"
-		event = l.logger.Warn()
+	if vars == nil {
+		vars = []interface{}{}
 	}
 
-	// Try to get per-request logger from Fiber context if available
-	// This ensures SQL logs include the same request_id as application logs
-	if fiberCtx, ok := ctx.Value(internal.FiberContextKey).(*fiber.Ctx); ok && fiberCtx != nil {
-		// Add validation to check if the Fiber context is still valid
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// If panic occurs, silently continue with the default logger
-					return
-				}
-			}()
-
-			if requestLogger := fiberCtx.Locals(internal.RequestLoggerKey); requestLogger != nil {
-				// Use the per-request logger which already has request_id in context
-				loggerWithRequestID := requestLogger.(*zerolog.Logger)
-				event = loggerWithRequestID.Info()
-				if err != nil {
-					event = loggerWithRequestID.Error().Err(err)
-				} else if latency > l.config.SlowThreshold {
-					msg = "Slow query"
-					event = loggerWithRequestID.Warn()
-				}
-			}
-		}()
+	return sql, rows, vars
+}
+
+// loggerFrom returns the per-request logger stored in ctx, see xgo.RequestContext,
+// falling back to the logger given to NewZerologGormLogger.
+func (l *ZerologGormLogger) loggerFrom(ctx context.Context) *zerolog.Logger {
+	if logger := internal.LoggerFromContext(ctx); logger != nil {
+		return logger
 	}
 
-	arr := zerolog.Arr()
-	arr.Str(utils.FileWithLineNum())
+	return &l.logger
+}
 
-	event.Str("sql", sql).
-		Int64("rows", rows).
-		Str("latency", latency.String()).
-		Array("stack", arr).
-		Msg(msg)
+// recordRequestStats adds the query to the request stats, used by the access
+// log for `db_time` and `db_queries`, and warns once per statement when it
+// looks like an N+1 query.
+func (l *ZerologGormLogger) recordRequestStats(ctx context.Context, stats *internal.RequestStats, sql string, latency time.Duration) {
+	if stats == nil {
+		return
+	}
+
+	normalized := NormalizeSql(sql)
+	count := stats.AddQuery(normalized, latency)
+
+	if l.NPlusOneThreshold > 0 && count == l.NPlusOneThreshold+1 {
+		l.loggerFrom(ctx).Warn().
+			Str("sql", l.Sql.truncate(normalized)).
+			Int("threshold", l.NPlusOneThreshold).
+			Msg("Possible N+1 query")
+	}
 }
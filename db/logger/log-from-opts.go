@@ -7,10 +7,10 @@ import (
 
 func LogFromOpts(opts ...gorm.Option) gormlogger.Interface {
 	var log gormlogger.Interface
-	if len(opts) > 0 {
-		cfg := opts[0].(*gorm.Config)
-		if cfg != nil {
+	for _, opt := range opts {
+		if cfg, ok := opt.(*gorm.Config); ok && cfg != nil && cfg.Logger != nil {
 			log = cfg.Logger
+			break
 		}
 	}
 
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumberLiteral  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlPlaceholder    = regexp.MustCompile(`\$\d+|@p\d+`)
+	sqlPlaceholderSet = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeSql replaces literals and placeholders with `?`, collapses `IN`
+// lists and whitespace, so the same statement with different values yields
+// the same text.
+func NormalizeSql(sql string) string {
+	normalized := sqlStringLiteral.ReplaceAllString(sql, "?")
+	normalized = sqlPlaceholder.ReplaceAllString(normalized, "?")
+	normalized = sqlNumberLiteral.ReplaceAllString(normalized, "?")
+	normalized = sqlPlaceholderSet.ReplaceAllString(normalized, "(?)")
+	normalized = sqlWhitespace.ReplaceAllString(normalized, " ")
+
+	return strings.TrimSpace(normalized)
+}
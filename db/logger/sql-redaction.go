@@ -0,0 +1,299 @@
+package logger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactPatterns matches the column names whose values are redacted by default.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)pass(word)?|secret|token|api_?key|credential`),
+}
+
+// SqlLogConfig controls how statements are written by ZerologGormLogger.
+type SqlLogConfig struct {
+	// Parameterized logs the statement with its placeholders and the bound
+	// values separately in `vars`, instead of the interpolated statement.
+	// Also enabled by gormlogger.Config.ParameterizedQueries.
+	Parameterized bool
+
+	// RedactColumns lists the columns (case insensitive) whose bound values are
+	// replaced by RedactedValue.
+	RedactColumns []string
+
+	// RedactPatterns are matched against column names, a match is redacted.
+	// Default: DefaultRedactPatterns.
+	RedactPatterns []*regexp.Regexp
+
+	// MaxLength truncates longer statements. 0 disables truncation.
+	MaxLength int
+
+	// Fingerprint adds a `fingerprint` field, a hash of the normalized
+	// statement, to group the same query with different values.
+	Fingerprint bool
+}
+
+func (c SqlLogConfig) isRedacted(column string) bool {
+	if column == "" {
+		return false
+	}
+
+	for _, redacted := range c.RedactColumns {
+		if strings.EqualFold(redacted, column) {
+			return true
+		}
+	}
+
+	for _, pattern := range c.RedactPatterns {
+		if pattern.MatchString(column) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns a copy of vars where the values bound to redacted columns
+// are replaced by RedactedValue.
+func (c SqlLogConfig) redact(sql string, vars []interface{}) []interface{} {
+	if len(vars) == 0 || (len(c.RedactColumns) == 0 && len(c.RedactPatterns) == 0) {
+		return vars
+	}
+
+	var redacted []interface{}
+	for index, column := range placeholderColumns(sql) {
+		if index >= len(vars) || !c.isRedacted(column) {
+			continue
+		}
+
+		if redacted == nil {
+			redacted = make([]interface{}, len(vars))
+			copy(redacted, vars)
+		}
+		redacted[index] = RedactedValue
+	}
+
+	if redacted == nil {
+		return vars
+	}
+
+	return redacted
+}
+
+func (c SqlLogConfig) truncate(sql string) string {
+	if c.MaxLength <= 0 || len(sql) <= c.MaxLength {
+		return sql
+	}
+
+	return fmt.Sprintf("%s... (truncated %d bytes)", sql[:c.MaxLength], len(sql)-c.MaxLength)
+}
+
+// Fingerprint returns a short hash of the normalized statement, see NormalizeSql.
+func Fingerprint(sql string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(NormalizeSql(sql)))
+
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// placeholderColumns maps the index of every bound value of sql to the column
+// it is compared with or assigned to. It understands `?`, `$n` and `@pn`
+// placeholders, INSERT value lists, SET assignments, comparisons and IN lists,
+// skipping string literals, quoted identifiers and comments. Columns that
+// cannot be resolved are left empty.
+func placeholderColumns(sql string) map[int]string {
+	columns := map[int]string{}
+	insertColumns := insertColumnList(sql)
+	valuesAt := -1
+	if insertColumns != nil {
+		valuesAt = indexFold(sql, " VALUES")
+	}
+
+	sequence := 0
+	depth := 0
+	inValues := false
+	tupleField := 0
+	lastColumn := ""
+
+	for i := 0; i < len(sql); i++ {
+		if end := skipLiteral(sql, i); end > i {
+			i = end
+			continue
+		}
+
+		c := sql[i]
+
+		switch c {
+		case '(':
+			depth++
+			if inValues && depth == 1 {
+				tupleField = 0
+			}
+			continue
+		case ')':
+			depth--
+			continue
+		case ',':
+			if inValues && depth == 1 {
+				tupleField++
+			}
+			continue
+		}
+
+		if valuesAt >= 0 && i == valuesAt {
+			inValues = true
+		}
+
+		if inValues && depth == 0 && i > valuesAt+len(" VALUES") && c != ' ' && c != '\n' && c != '\t' && c != '\r' {
+			// past the value tuples, e.g. ON CONFLICT or RETURNING
+			inValues = false
+		}
+
+		index, length := placeholderAt(sql, i, &sequence)
+		if index < 0 {
+			continue
+		}
+
+		column := ""
+		if inValues && depth == 1 {
+			if tupleField < len(insertColumns) {
+				column = insertColumns[tupleField]
+			}
+		} else if strings.HasSuffix(strings.TrimRight(sql[:i], " \t\r\n"), ",") && depth > 0 {
+			// next value of an IN list
+			column = lastColumn
+		} else {
+			column = precedingColumn(sql[:i])
+		}
+
+		columns[index] = column
+		lastColumn = column
+		i += length - 1
+	}
+
+	return columns
+}
+
+// skipLiteral returns the index of the last character of the string literal,
+// quoted identifier or comment starting at i, or i when there is none, so
+// that their quotes and question marks are not mistaken for placeholders.
+func skipLiteral(sql string, i int) int {
+	switch c := sql[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		// a doubled quote is escaped, it is skipped as two literals
+		if end := strings.IndexByte(sql[i+1:], c); end >= 0 {
+			return i + 1 + end
+		}
+		return len(sql) - 1
+	case c == '-' && strings.HasPrefix(sql[i:], "--"):
+		if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(sql) - 1
+	case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+		if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+			return i + 2 + end + 1
+		}
+		return len(sql) - 1
+	}
+
+	return i
+}
+
+// placeholderAt returns the bound value index and the length of the
+// placeholder starting at i, or -1 when there is none.
+func placeholderAt(sql string, i int, sequence *int) (int, int) {
+	c := sql[i]
+
+	if c == '?' {
+		index := *sequence
+		*sequence++
+		return index, 1
+	}
+
+	if c == '$' || (c == '@' && i+1 < len(sql) && (sql[i+1] == 'p' || sql[i+1] == 'P')) {
+		start := i + 1
+		if c == '@' {
+			start++
+		}
+
+		end := start
+		for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+			end++
+		}
+
+		if end == start {
+			return -1, 0
+		}
+
+		var number int
+		fmt.Sscanf(sql[start:end], "%d", &number)
+
+		return number - 1, end - i
+	}
+
+	return -1, 0
+}
+
+var sqlComparisonSuffix = regexp.MustCompile(`(?i)(\s*(=|<>|!=|<=|>=|<|>|\(|\bNOT\b|\bLIKE\b|\bILIKE\b|\bIN\b|\bIS\b))+\s*$`)
+
+var sqlIdentifierSuffix = regexp.MustCompile("[\\w\"`\\[\\].]+$")
+
+// precedingColumn returns the column name right before a comparison or an
+// assignment operator at the end of before.
+func precedingColumn(before string) string {
+	trimmed := sqlComparisonSuffix.ReplaceAllString(before, "")
+	if trimmed == before {
+		return ""
+	}
+
+	identifier := sqlIdentifierSuffix.FindString(trimmed)
+	if identifier == "" {
+		return ""
+	}
+
+	return unquoteIdentifier(identifier)
+}
+
+// insertColumnList returns the column list of an INSERT statement, or nil.
+func insertColumnList(sql string) []string {
+	trimmed := strings.TrimSpace(sql)
+	if len(trimmed) < 6 || !strings.EqualFold(trimmed[:6], "INSERT") {
+		return nil
+	}
+
+	valuesAt := indexFold(trimmed, " VALUES")
+	if valuesAt < 0 {
+		return nil
+	}
+
+	head := trimmed[:valuesAt]
+	open := strings.Index(head, "(")
+	closing := strings.LastIndex(head, ")")
+	if open < 0 || closing < open {
+		return nil
+	}
+
+	var columns []string
+	for _, column := range strings.Split(head[open+1:closing], ",") {
+		columns = append(columns, unquoteIdentifier(strings.TrimSpace(column)))
+	}
+
+	return columns
+}
+
+func unquoteIdentifier(identifier string) string {
+	if dot := strings.LastIndex(identifier, "."); dot >= 0 {
+		identifier = identifier[dot+1:]
+	}
+
+	return strings.ToLower(strings.Trim(identifier, "\"`[]"))
+}
+
+func indexFold(s string, substr string) int {
+	return strings.Index(strings.ToUpper(s), strings.ToUpper(substr))
+}
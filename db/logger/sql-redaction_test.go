@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	const r = RedactedValue
+
+	tests := []struct {
+		name string
+		sql  string
+		vars []interface{}
+		want []interface{}
+	}{
+		{
+			name: "question marks",
+			sql:  "UPDATE users SET name = ?, password = ? WHERE id = ?",
+			vars: []interface{}{"ann", "p", 1},
+			want: []interface{}{"ann", r, 1},
+		},
+		{
+			name: "postgres placeholders",
+			sql:  `INSERT INTO "users" ("name","password") VALUES ($1,$2) RETURNING "id"`,
+			vars: []interface{}{"ann", "p"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "postgres placeholders out of order",
+			sql:  `UPDATE "users" SET "password" = $2 WHERE "name" = $1`,
+			vars: []interface{}{"ann", "p"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "sqlserver placeholders",
+			sql:  "SELECT * FROM [users] WHERE [name] = @p1 AND [api_key] = @p2",
+			vars: []interface{}{"ann", "k"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "mysql rows",
+			sql:  "INSERT INTO `users` (`name`,`secret`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+			vars: []interface{}{"ann", "s1", "bob", "s2"},
+			want: []interface{}{"ann", r, "bob", r},
+		},
+		{
+			name: "in list",
+			sql:  "SELECT * FROM users WHERE token IN (?,?) AND id = ?",
+			vars: []interface{}{"t1", "t2", 1},
+			want: []interface{}{r, r, 1},
+		},
+		{
+			name: "embedded quotes",
+			sql:  "SELECT * FROM users WHERE note = 'it''s = ?' AND name = ? AND password = ?",
+			vars: []interface{}{"ann", "p"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "empty string",
+			sql:  "UPDATE users SET note = '', password = ? WHERE id = ?",
+			vars: []interface{}{"p", 1},
+			want: []interface{}{r, 1},
+		},
+		{
+			name: "quoted identifiers with quotes",
+			sql:  `SELECT "it's" AS "what?" FROM users WHERE name = $1 AND password = $2`,
+			vars: []interface{}{"ann", "p"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "line comment",
+			sql:  "-- who's there?\nSELECT * FROM users WHERE name = ? AND password = ? -- password = ?",
+			vars: []interface{}{"ann", "p"},
+			want: []interface{}{"ann", r},
+		},
+		{
+			name: "block comment",
+			sql:  "/* it's ? */ UPDATE users SET password = ? /* , name = ? */ WHERE id = ?",
+			vars: []interface{}{"p", 1},
+			want: []interface{}{r, 1},
+		},
+		{
+			name: "unterminated string",
+			sql:  "SELECT * FROM users WHERE password = ? AND name = 'ann",
+			vars: []interface{}{"p"},
+			want: []interface{}{r},
+		},
+		{
+			name: "nothing redacted",
+			sql:  "SELECT * FROM users WHERE name = ?",
+			vars: []interface{}{"ann"},
+			want: []interface{}{"ann"},
+		},
+	}
+
+	config := SqlLogConfig{RedactPatterns: DefaultRedactPatterns}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars := append([]interface{}{}, test.vars...)
+
+			if got := config.redact(test.sql, vars); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+
+			if !reflect.DeepEqual(vars, test.vars) {
+				t.Fatalf("expected the vars to be left unchanged, got %v", vars)
+			}
+		})
+	}
+}
+
+func TestRedactColumns(t *testing.T) {
+	config := SqlLogConfig{RedactColumns: []string{"EMAIL"}}
+
+	got := config.redact(`SELECT * FROM "users" WHERE "users"."email" = $1 AND "password" = $2`, []interface{}{"a@b.c", "p"})
+	if want := []interface{}{RedactedValue, "p"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only the listed columns to be redacted, %v, got %v", want, got)
+	}
+}
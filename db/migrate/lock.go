@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lock takes a session level advisory lock on conn, a pinned connection,
+// polling until it is acquired or the timeout elapses. The returned func
+// releases it. SQLite has no advisory locks, its writes are already serialized.
+func (m *Migrator) lock(ctx context.Context, conn *gorm.DB) (func(), error) {
+	key := "xgo_migrate:" + m.table()
+
+	var try func() (bool, error)
+	var release func()
+
+	switch m.dialect() {
+	case "postgres":
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		id := int64(hash.Sum64())
+
+		try = func() (bool, error) {
+			var locked bool
+			err := conn.Raw("SELECT pg_try_advisory_lock(?)", id).Scan(&locked).Error
+			return locked, err
+		}
+		release = func() {
+			conn.Exec("SELECT pg_advisory_unlock(?)", id)
+		}
+	case "sqlserver":
+		try = func() (bool, error) {
+			var result int
+			err := conn.Raw("DECLARE @result int; EXEC @result = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0; SELECT @result", key).
+				Scan(&result).Error
+			return result >= 0, err
+		}
+		release = func() {
+			conn.Exec("EXEC sp_releaseapplock @Resource = ?, @LockOwner = 'Session'", key)
+		}
+	case "mysql":
+		try = func() (bool, error) {
+			var result int
+			err := conn.Raw("SELECT COALESCE(GET_LOCK(?, 0), 0)", key).Scan(&result).Error
+			return result == 1, err
+		}
+		release = func() {
+			conn.Exec("SELECT RELEASE_LOCK(?)", key)
+		}
+	default:
+		return func() {}, nil
+	}
+
+	deadline := time.Now().Add(m.options.LockTimeout)
+	for {
+		locked, err := try()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		if locked {
+			return release, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w after %s", ErrLocked, m.options.LockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
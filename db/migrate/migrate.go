@@ -0,0 +1,433 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/anoaland/xgo/db/replica"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration was edited.
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrNoDownMigration is returned when a migration to revert has no down migration.
+	ErrNoDownMigration = errors.New("no down migration")
+	// ErrUnknownTarget is returned when Options.Target is not a migration version.
+	ErrUnknownTarget = errors.New("unknown target version")
+	// ErrLocked is returned when another migration run holds the lock.
+	ErrLocked = errors.New("migrations are locked by another run")
+	// ErrDuplicateVersion is returned when several files share a version.
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	// ErrInvalidMigration is returned for a down migration without up migration.
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrOutOfOrder is returned when a pending migration is older than the
+	// latest applied one, e.g. after merging branches, see AllowOutOfOrder.
+	ErrOutOfOrder = errors.New("pending migration older than the latest applied one")
+)
+
+// MigrationError describes a failed statement. The migration is rolled back,
+// unless it runs without transaction.
+type MigrationError struct {
+	Version   string
+	Statement string
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %s failed: %v\n%s", e.Version, e.Err, e.Statement)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+type Options struct {
+	// RevisionsSchema is the schema of the revisions table, e.g. the
+	// RevisionsSchema of atlas.AtlasConfig. It is created when missing.
+	// Optional. Default: the current schema.
+	RevisionsSchema string
+	// RevisionsTable is the table recording the applied versions.
+	// Optional. Default: "xgo_schema_revisions".
+	RevisionsTable string
+	// Target is the version to migrate up to, included, or down to, excluded.
+	// Optional. Default: Up applies every pending migration, Down reverts the
+	// last applied one. "0" reverts every migration.
+	Target string
+	// DryRun returns the migrations which would run, without running them.
+	DryRun bool
+	// LockTimeout is the time to wait for a concurrent run to complete.
+	// Optional. Default: 1m.
+	LockTimeout time.Duration
+	// AllowOutOfOrder applies the pending migrations older than the latest
+	// applied one, instead of failing with ErrOutOfOrder.
+	// Optional. Default: false.
+	AllowOutOfOrder bool
+}
+
+// Migrator runs versioned SQL migrations read from a directory, without the
+// atlas CLI. Every migration runs in its own transaction, together with the
+// update of the revisions table, and concurrent runs are serialized with an
+// advisory lock.
+type Migrator struct {
+	db         *gorm.DB
+	options    Options
+	migrations []*Migration
+}
+
+// Revision is a row of the revisions table.
+type Revision struct {
+	Version       string `gorm:"primaryKey;size:255"`
+	Description   string `gorm:"size:255"`
+	Checksum      string `gorm:"size:64"`
+	AppliedAt     time.Time
+	ExecutionTime int64 // milliseconds
+}
+
+// Status is the state of a migration, see Migrator.Status.
+type Status struct {
+	Version     string
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+	// Modified is set when the migration was edited after it was applied.
+	Modified bool
+	// Missing is set when an applied migration is not in the directory.
+	Missing bool
+	// OutOfOrder is set when a pending migration is older than the latest
+	// applied one, see ErrOutOfOrder.
+	OutOfOrder bool
+}
+
+// New reads the migrations of dir, see Load.
+func New(db *gorm.DB, dir fs.FS, opts ...Options) (*Migrator, error) {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	if options.RevisionsTable == "" {
+		options.RevisionsTable = "xgo_schema_revisions"
+	}
+
+	if options.LockTimeout <= 0 {
+		options.LockTimeout = time.Minute
+	}
+
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: replica.UsePrimary(db), options: options, migrations: migrations}, nil
+}
+
+// Migrations returns the migrations read from the directory.
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Up applies the pending migrations up to Options.Target and returns them.
+// An applied migration edited since fails with ErrChecksumMismatch.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	if m.options.Target != "" && m.find(m.options.Target) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTarget, m.options.Target)
+	}
+
+	return m.run(ctx, "Applying", m.planUp, m.apply)
+}
+
+// Down reverts the applied migrations after Options.Target, or the last
+// applied one, and returns them. Every migration to revert must have a down
+// migration.
+func (m *Migrator) Down(ctx context.Context) ([]*Migration, error) {
+	if m.options.Target != "" && m.options.Target != "0" && m.find(m.options.Target) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTarget, m.options.Target)
+	}
+
+	return m.run(ctx, "Reverting", m.planDown, m.revert)
+}
+
+// Status returns the state of every migration of the directory, followed by
+// the applied migrations missing from it.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	revisions, err := m.revisions(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	latest := latestVersion(revisions)
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Description: migration.Description}
+		if revision, ok := revisions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = revision.AppliedAt
+			status.Modified = revision.Checksum != migration.Checksum
+		} else {
+			status.OutOfOrder = latest != "" && CompareVersions(migration.Version, latest) < 0
+		}
+		statuses = append(statuses, status)
+	}
+
+	var missing []Status
+	for version, revision := range revisions {
+		if m.find(version) == nil {
+			missing = append(missing, Status{
+				Version:     version,
+				Description: revision.Description,
+				Applied:     true,
+				AppliedAt:   revision.AppliedAt,
+				Missing:     true,
+			})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return CompareVersions(missing[i].Version, missing[j].Version) < 0
+	})
+
+	return append(statuses, missing...), nil
+}
+
+type planner func(revisions map[string]Revision) ([]*Migration, error)
+
+type runner func(conn *gorm.DB, migration *Migration) error
+
+func (m *Migrator) run(ctx context.Context, action string, plan planner, execute runner) ([]*Migration, error) {
+	db := m.db.WithContext(ctx)
+
+	if m.options.DryRun {
+		revisions, err := m.revisions(db)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations, err := plan(revisions)
+		for _, migration := range migrations {
+			db.Logger.Info(ctx, "[dry-run] %s migration %s %s", action, migration.Version, migration.Description)
+		}
+		return migrations, err
+	}
+
+	var done []*Migration
+	err := db.Connection(func(conn *gorm.DB) error {
+		unlock, err := m.lock(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := m.createRevisionsTable(conn); err != nil {
+			return err
+		}
+
+		revisions, err := m.revisions(conn)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := plan(revisions)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			conn.Logger.Info(ctx, "%s migration %s %s", action, migration.Version, migration.Description)
+
+			if err := execute(conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+func (m *Migrator) planUp(revisions map[string]Revision) ([]*Migration, error) {
+	latest := latestVersion(revisions)
+
+	var pending []*Migration
+	for _, migration := range m.migrations {
+		revision, applied := revisions[migration.Version]
+		if applied {
+			if revision.Checksum != migration.Checksum {
+				return nil, fmt.Errorf("%w: version %s", ErrChecksumMismatch, migration.Version)
+			}
+			continue
+		}
+
+		if !m.options.AllowOutOfOrder && latest != "" && CompareVersions(migration.Version, latest) < 0 {
+			return nil, fmt.Errorf("%w: version %s is older than %s", ErrOutOfOrder, migration.Version, latest)
+		}
+
+		if m.options.Target != "" && CompareVersions(migration.Version, m.options.Target) > 0 {
+			break
+		}
+
+		pending = append(pending, migration)
+	}
+
+	return pending, nil
+}
+
+// latestVersion returns the latest applied version, or an empty string.
+func latestVersion(revisions map[string]Revision) string {
+	latest := ""
+	for version := range revisions {
+		if latest == "" || CompareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	return latest
+}
+
+func (m *Migrator) planDown(revisions map[string]Revision) ([]*Migration, error) {
+	var applied []*Migration
+	for _, migration := range m.migrations {
+		if _, ok := revisions[migration.Version]; ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	var reverted []*Migration
+	for i := len(applied) - 1; i >= 0; i-- {
+		migration := applied[i]
+
+		if m.options.Target == "" && len(reverted) == 1 {
+			break
+		}
+
+		if m.options.Target != "" && CompareVersions(migration.Version, m.options.Target) <= 0 {
+			break
+		}
+
+		if migration.Down == "" {
+			return nil, fmt.Errorf("%w: version %s", ErrNoDownMigration, migration.Version)
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+func (m *Migrator) apply(conn *gorm.DB, migration *Migration) error {
+	start := time.Now()
+
+	return m.transaction(conn, migration.NoTransaction, func(tx *gorm.DB) error {
+		if err := m.exec(tx, migration.Version, migration.Up); err != nil {
+			return err
+		}
+
+		return tx.Table(m.table()).Create(&Revision{
+			Version:       migration.Version,
+			Description:   migration.Description,
+			Checksum:      migration.Checksum,
+			AppliedAt:     time.Now().UTC(),
+			ExecutionTime: time.Since(start).Milliseconds(),
+		}).Error
+	})
+}
+
+func (m *Migrator) revert(conn *gorm.DB, migration *Migration) error {
+	return m.transaction(conn, migration.NoTransaction, func(tx *gorm.DB) error {
+		if err := m.exec(tx, migration.Version, migration.Down); err != nil {
+			return err
+		}
+
+		return tx.Table(m.table()).Where("version = ?", migration.Version).Delete(&Revision{}).Error
+	})
+}
+
+func (m *Migrator) transaction(conn *gorm.DB, noTransaction bool, fc func(tx *gorm.DB) error) error {
+	if noTransaction {
+		return fc(conn)
+	}
+
+	return conn.Transaction(fc)
+}
+
+func (m *Migrator) exec(tx *gorm.DB, version string, sql string) error {
+	for _, statement := range SplitStatements(sql, m.dialect()) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return &MigrationError{Version: version, Statement: statement, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) find(version string) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) dialect() string {
+	return m.db.Dialector.Name()
+}
+
+func (m *Migrator) table() string {
+	if m.options.RevisionsSchema == "" || m.dialect() == "sqlite" {
+		return m.options.RevisionsTable
+	}
+
+	return m.options.RevisionsSchema + "." + m.options.RevisionsTable
+}
+
+func (m *Migrator) createRevisionsTable(conn *gorm.DB) error {
+	if schema := m.options.RevisionsSchema; schema != "" {
+		var err error
+		switch m.dialect() {
+		case "postgres":
+			err = conn.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, schema)).Error
+		case "sqlserver":
+			err = conn.Exec(fmt.Sprintf(`IF SCHEMA_ID('%[1]s') IS NULL EXEC('CREATE SCHEMA [%[1]s]')`, schema)).Error
+		case "mysql":
+			err = conn.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", schema)).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create revisions schema: %w", err)
+		}
+	}
+
+	if err := conn.Table(m.table()).AutoMigrate(&Revision{}); err != nil {
+		return fmt.Errorf("failed to create revisions table: %w", err)
+	}
+
+	return nil
+}
+
+// revisions returns the applied migrations by version, none when the
+// revisions table does not exist yet.
+func (m *Migrator) revisions(db *gorm.DB) (map[string]Revision, error) {
+	revisions := map[string]Revision{}
+
+	if !db.Migrator().HasTable(m.table()) {
+		return revisions, nil
+	}
+
+	var rows []Revision
+	if err := db.Table(m.table()).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read revisions: %w", err)
+	}
+
+	for _, row := range rows {
+		revisions[row.Version] = row
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,257 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/anoaland/xgo/internal/testdb"
+	"gorm.io/gorm"
+)
+
+func migrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_users.up.sql":    {Data: []byte("CREATE TABLE users (id int);")},
+		"1_users.down.sql":  {Data: []byte("DROP TABLE users;")},
+		"2_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id int);\nCREATE TABLE order_lines (id int);")},
+		"2_orders.down.sql": {Data: []byte("DROP TABLE order_lines;\nDROP TABLE orders;")},
+		"3_items.up.sql":    {Data: []byte("CREATE TABLE items (id int);")},
+		"3_items.down.sql":  {Data: []byte("DROP TABLE items;")},
+		"atlas.sum":         {Data: []byte("h1:...")},
+	}
+}
+
+func migrate(t *testing.T, db *gorm.DB, dir fstest.MapFS, up bool, options Options) ([]*Migration, error) {
+	t.Helper()
+
+	migrator, err := New(db, dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if up {
+		return migrator.Up(context.Background())
+	}
+
+	return migrator.Down(context.Background())
+}
+
+// versions returns the versions of migrations, e.g. "1,2".
+func versions(migrations []*Migration) string {
+	result := ""
+	for i, migration := range migrations {
+		if i > 0 {
+			result += ","
+		}
+		result += migration.Version
+	}
+
+	return result
+}
+
+func expectTables(t *testing.T, db *gorm.DB, tables map[string]bool) {
+	t.Helper()
+
+	for table, exists := range tables {
+		if db.Migrator().HasTable(table) != exists {
+			t.Fatalf("expected the table %s to exist: %v", table, exists)
+		}
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+
+	applied, err := migrate(t, db, dir, true, Options{Target: "2"})
+	if err != nil || versions(applied) != "1,2" {
+		t.Fatalf("expected 1,2 to be applied up to the target, got %s (%v)", versions(applied), err)
+	}
+	expectTables(t, db, map[string]bool{"users": true, "orders": true, "order_lines": true, "items": false})
+
+	applied, err = migrate(t, db, dir, true, Options{})
+	if err != nil || versions(applied) != "3" {
+		t.Fatalf("expected 3 to be applied, got %s (%v)", versions(applied), err)
+	}
+
+	if applied, err = migrate(t, db, dir, true, Options{}); err != nil || len(applied) != 0 {
+		t.Fatalf("expected nothing to apply, got %s (%v)", versions(applied), err)
+	}
+
+	reverted, err := migrate(t, db, dir, false, Options{})
+	if err != nil || versions(reverted) != "3" {
+		t.Fatalf("expected the last migration to be reverted, got %s (%v)", versions(reverted), err)
+	}
+	expectTables(t, db, map[string]bool{"items": false, "orders": true})
+
+	if _, err := migrate(t, db, dir, false, Options{Target: "9"}); !errors.Is(err, ErrUnknownTarget) {
+		t.Fatalf("expected ErrUnknownTarget, got %v", err)
+	}
+
+	reverted, err = migrate(t, db, dir, false, Options{Target: "0"})
+	if err != nil || versions(reverted) != "2,1" {
+		t.Fatalf("expected every migration to be reverted, got %s (%v)", versions(reverted), err)
+	}
+	expectTables(t, db, map[string]bool{"users": false, "orders": false, "order_lines": false})
+
+	var count int64
+	if err := db.Table("xgo_schema_revisions").Count(&count).Error; err != nil || count != 0 {
+		t.Fatalf("expected no revision left, got %d (%v)", count, err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+
+	planned, err := migrate(t, db, dir, true, Options{DryRun: true})
+	if err != nil || versions(planned) != "1,2,3" {
+		t.Fatalf("expected every migration to be planned, got %s (%v)", versions(planned), err)
+	}
+	expectTables(t, db, map[string]bool{"users": false, "xgo_schema_revisions": false})
+
+	if _, err := migrate(t, db, dir, true, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	planned, err = migrate(t, db, dir, false, Options{DryRun: true, Target: "1"})
+	if err != nil || versions(planned) != "3,2" {
+		t.Fatalf("expected 3,2 to be planned, got %s (%v)", versions(planned), err)
+	}
+	expectTables(t, db, map[string]bool{"items": true, "orders": true})
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+
+	if _, err := migrate(t, db, dir, true, Options{Target: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir["1_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id int, name text);")}
+	if _, err := migrate(t, db, dir, true, Options{}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	expectTables(t, db, map[string]bool{"orders": false})
+
+	migrator, err := New(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil || !statuses[0].Modified || statuses[1].Applied {
+		t.Fatalf("expected 1 to be modified and 2 pending, got %+v (%v)", statuses, err)
+	}
+}
+
+func TestMissingDownMigration(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+	delete(dir, "2_orders.down.sql")
+
+	if _, err := migrate(t, db, dir, true, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrate(t, db, dir, false, Options{Target: "1"}); !errors.Is(err, ErrNoDownMigration) {
+		t.Fatalf("expected ErrNoDownMigration, got %v", err)
+	}
+	expectTables(t, db, map[string]bool{"items": true})
+}
+
+func TestFailedMigrationTransaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		partial bool
+	}{
+		{"transaction", "CREATE TABLE users (id int);\nINSERT INTO missing VALUES (1);", false},
+		{"no transaction", "-- atlas:txmode none\nCREATE TABLE users (id int);\nINSERT INTO missing VALUES (1);", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testdb.New(t)
+			dir := fstest.MapFS{"1_users.sql": {Data: []byte(test.sql)}}
+
+			migrator, err := New(db, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrator.Migrations()[0].NoTransaction != test.partial {
+				t.Fatalf("expected NoTransaction to be %v", test.partial)
+			}
+
+			_, err = migrator.Up(context.Background())
+			var migrationErr *MigrationError
+			if !errors.As(err, &migrationErr) || migrationErr.Version != "1" || migrationErr.Statement != "INSERT INTO missing VALUES (1)" {
+				t.Fatalf("expected a MigrationError of the insert, got %v", err)
+			}
+
+			expectTables(t, db, map[string]bool{"users": test.partial})
+
+			statuses, err := migrator.Status(context.Background())
+			if err != nil || statuses[0].Applied {
+				t.Fatalf("expected the failed migration to be pending, got %+v (%v)", statuses, err)
+			}
+		})
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+	late := dir["2_orders.up.sql"]
+	delete(dir, "2_orders.up.sql")
+	delete(dir, "2_orders.down.sql")
+
+	if _, err := migrate(t, db, dir, true, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir["2_orders.up.sql"] = late
+	if _, err := migrate(t, db, dir, true, Options{}); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("expected ErrOutOfOrder, got %v", err)
+	}
+	expectTables(t, db, map[string]bool{"orders": false})
+
+	migrator, err := New(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil || statuses[1].Version != "2" || !statuses[1].OutOfOrder {
+		t.Fatalf("expected 2 to be reported out of order, got %+v (%v)", statuses, err)
+	}
+
+	applied, err := migrate(t, db, dir, true, Options{AllowOutOfOrder: true})
+	if err != nil || versions(applied) != "2" {
+		t.Fatalf("expected 2 to be applied when allowed, got %s (%v)", versions(applied), err)
+	}
+}
+
+func TestMissingMigration(t *testing.T) {
+	db := testdb.New(t)
+	dir := migrations()
+
+	if _, err := migrate(t, db, dir, true, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	delete(dir, "3_items.up.sql")
+	delete(dir, "3_items.down.sql")
+
+	migrator, err := New(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrator.Status(context.Background())
+	if err != nil || len(statuses) != 3 || statuses[2].Version != "3" || !statuses[2].Missing {
+		t.Fatalf("expected 3 to be reported missing, got %+v (%v)", statuses, err)
+	}
+}
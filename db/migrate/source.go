@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Migration is a versioned migration read from a directory.
+type Migration struct {
+	Version     string
+	Description string
+	// Up and Down are the SQL of the migration, Down is empty when the
+	// migration can not be reverted.
+	Up   string
+	Down string
+	// Checksum is the SHA-256 of Up, recorded in the revisions table to
+	// detect migrations edited after they were applied.
+	Checksum string
+	// NoTransaction is set by the `-- atlas:txmode none` directive, for
+	// statements which can not run in a transaction.
+	NoTransaction bool
+}
+
+// migrationFile matches `<version>_<description>.sql`, the atlas layout, and
+// `<version>_<description>.up.sql` / `.down.sql` pairs.
+var migrationFile = regexp.MustCompile(`^(\d+)(?:_(.*?))?(\.up|\.down)?\.sql$`)
+
+var txModeNone = regexp.MustCompile(`(?im)^--\s*atlas:txmode\s+none\s*$`)
+
+// Load reads the migrations of dir, sorted by version. Other files, like
+// atlas.sum, are ignored.
+func Load(dir fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[string]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		content, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		version, description, direction := strings.TrimLeft(match[1], "0"), match[2], match[3]
+		if version == "" {
+			version = "0"
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Description: description}
+			byVersion[version] = migration
+		} else if migration.Description != description {
+			return nil, fmt.Errorf("%w: version %s is used by '%s' and '%s'", ErrDuplicateVersion, version, migration.Description, description)
+		}
+
+		if direction == ".down" {
+			if migration.Down != "" {
+				return nil, fmt.Errorf("%w: version %s has several down migrations", ErrDuplicateVersion, version)
+			}
+			migration.Down = string(content)
+			continue
+		}
+
+		if migration.Up != "" {
+			return nil, fmt.Errorf("%w: version %s has several up migrations", ErrDuplicateVersion, version)
+		}
+		migration.Up = string(content)
+		migration.Checksum = checksum(content)
+		migration.NoTransaction = txModeNone.Match(content)
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("%w: version %s has no up migration", ErrInvalidMigration, migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return CompareVersions(migrations[i].Version, migrations[j].Version) < 0
+	})
+
+	return migrations, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareVersions compares two numeric versions without leading zeros, as
+// returned by Load.
+func CompareVersions(a string, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+
+	return strings.Compare(a, b)
+}
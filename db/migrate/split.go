@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// noSplit matches the `-- migrate:no-split` directive of a migration to be
+// run as a single statement.
+var noSplit = regexp.MustCompile(`(?im)^--\s*migrate:no-split\s*$`)
+
+// SplitStatements splits a migration into its statements, on semicolons
+// outside of quotes, comments and Postgres dollar-quoted bodies. For SQL
+// Server, only `GO` lines end a batch, so that procedure and trigger bodies
+// stay whole. For MySQL, a `DELIMITER` line changes the delimiter, as in the
+// mysql client. Statements consisting only of comments are dropped. A
+// migration with the `-- migrate:no-split` directive is returned whole.
+func SplitStatements(sql string, dialect string) []string {
+	if noSplit.MatchString(sql) {
+		return []string{strings.TrimSpace(sql)}
+	}
+
+	var statements []string
+
+	start := 0
+	hasCode := false
+
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(sql[start:end]))
+		}
+		hasCode = false
+	}
+
+	delimiter := ";"
+	if dialect == "sqlserver" {
+		delimiter = ""
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			i = indexFrom(sql, i, "\n")
+		case c == '#' && dialect == "mysql":
+			i = indexFrom(sql, i, "\n")
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			i = indexFrom(sql, i+2, "*/")
+		case c == '\'' || c == '"' || c == '`':
+			hasCode = true
+			i = closingQuote(sql, i, c)
+		case c == '$' && dialect == "postgres":
+			hasCode = true
+			if tag, ok := dollarTag(sql, i); ok {
+				i = indexFrom(sql, i+len(tag), tag)
+			}
+		case dialect == "mysql" && !hasCode && isDelimiterCommand(sql, i):
+			end := indexFrom(sql, i, "\n")
+			if fields := strings.Fields(sql[i:end]); len(fields) > 1 {
+				delimiter = fields[1]
+			}
+			i = end
+			start = i + 1
+		case delimiter != "" && strings.HasPrefix(sql[i:], delimiter):
+			flush(i)
+			i += len(delimiter) - 1
+			start = i + 1
+		case dialect == "sqlserver" && isBatchSeparator(sql, i):
+			flush(i)
+			i = indexFrom(sql, i, "\n")
+			start = i + 1
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			hasCode = true
+		}
+	}
+
+	flush(len(sql))
+
+	return statements
+}
+
+// indexFrom returns the index of the last byte of substr found from i, or
+// the end of s.
+func indexFrom(s string, i int, substr string) int {
+	if i >= len(s) {
+		return len(s)
+	}
+
+	index := strings.Index(s[i:], substr)
+	if index < 0 {
+		return len(s)
+	}
+
+	return i + index + len(substr) - 1
+}
+
+// closingQuote returns the index of the quote closing the one at i, a
+// doubled quote being an escaped one.
+func closingQuote(s string, i int, quote byte) int {
+	for i++; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+// dollarTag returns the `$tag$` starting at i, not to be confused with a
+// `$1` placeholder.
+func dollarTag(s string, i int) (string, bool) {
+	if i > 0 && isIdentifierByte(s[i-1]) {
+		return "", false
+	}
+
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return s[i : j+1], true
+		}
+		if !isIdentifierByte(s[j]) || (j == i+1 && s[j] >= '0' && s[j] <= '9') {
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isBatchSeparator reports whether a line with only `GO` starts at i.
+func isBatchSeparator(s string, i int) bool {
+	if i > 0 && s[i-1] != '\n' {
+		return false
+	}
+
+	end := strings.IndexByte(s[i:], '\n')
+	if end < 0 {
+		end = len(s) - i
+	}
+
+	return strings.EqualFold(strings.TrimSpace(s[i:i+end]), "GO")
+}
+
+// isDelimiterCommand reports whether a `DELIMITER` command of the mysql client
+// starts at i.
+func isDelimiterCommand(s string, i int) bool {
+	const command = "DELIMITER"
+	if len(s)-i <= len(command) || !strings.EqualFold(s[i:i+len(command)], command) {
+		return false
+	}
+
+	return s[i+len(command)] == ' ' || s[i+len(command)] == '\t'
+}
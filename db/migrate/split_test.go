@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		sql     string
+		want    []string
+	}{
+		{
+			name:    "semicolons",
+			dialect: "postgres",
+			sql:     "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n",
+			want:    []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"},
+		},
+		{
+			name:    "comments only",
+			dialect: "postgres",
+			sql:     "-- a comment;\n/* another; */\nSELECT 1;\n-- trailing",
+			want:    []string{"-- a comment;\n/* another; */\nSELECT 1"},
+		},
+		{
+			name:    "quotes",
+			dialect: "postgres",
+			sql:     `INSERT INTO a VALUES ('a;b', 'it''s;');INSERT INTO "b;c" VALUES (1);`,
+			want:    []string{`INSERT INTO a VALUES ('a;b', 'it''s;')`, `INSERT INTO "b;c" VALUES (1)`},
+		},
+		{
+			name:    "postgres dollar quotes",
+			dialect: "postgres",
+			sql:     "CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;\nSELECT $1;",
+			want:    []string{"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql", "SELECT $1"},
+		},
+		{
+			name:    "sqlserver batches",
+			dialect: "sqlserver",
+			sql:     "CREATE TABLE a (id int);\nGO\nCREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND\n  go  \nSELECT 3;",
+			want:    []string{"CREATE TABLE a (id int);", "CREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND", "SELECT 3;"},
+		},
+		{
+			name:    "sqlserver go in a string",
+			dialect: "sqlserver",
+			sql:     "INSERT INTO a VALUES ('\nGO\n');\nGO",
+			want:    []string{"INSERT INTO a VALUES ('\nGO\n');"},
+		},
+		{
+			name:    "mysql delimiter",
+			dialect: "mysql",
+			sql:     "CREATE TABLE a (id int);\nDELIMITER $$\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.id = 1;\nEND$$\ndelimiter ;\n# a comment;\nSELECT 1;",
+			want:    []string{"CREATE TABLE a (id int)", "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW\nBEGIN\n  SET NEW.id = 1;\nEND", "# a comment;\nSELECT 1"},
+		},
+		{
+			name:    "delimiter outside mysql",
+			dialect: "postgres",
+			sql:     "SELECT 1 AS delimiter;",
+			want:    []string{"SELECT 1 AS delimiter"},
+		},
+		{
+			name:    "no split",
+			dialect: "mysql",
+			sql:     "-- migrate:no-split\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND;\n",
+			want:    []string{"-- migrate:no-split\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND;"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SplitStatements(test.sql, test.dialect); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
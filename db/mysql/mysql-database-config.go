@@ -0,0 +1,140 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/anoaland/xgo/utils"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+type MysqlDatabaseConfig struct {
+	Name     string `json:"name" env:"DB_NAME" required:"true"`
+	Host     string `json:"host" env:"DB_HOST" required:"true"`
+	User     string `json:"user" env:"DB_USER" required:"true"`
+	Password string `json:"password" env:"DB_PASSWORD" required:"true"`
+	Port     string `json:"port" env:"DB_PORT" default:"3306" required:"true"`
+
+	// Charset of the connection.
+	// Optional. Default: "utf8mb4".
+	Charset string `json:"charset,omitempty" env:"DB_CHARSET"`
+	// TimeZone is the location of DATETIME values, e.g. "UTC" or "Asia/Jakarta".
+	// Optional. Default: "UTC".
+	TimeZone string `json:"timeZone,omitempty" env:"DB_TIMEZONE"`
+	// Tls is "true", "skip-verify", "preferred" or a name registered with
+	// mysql.RegisterTLSConfig.
+	Tls string `json:"tls,omitempty" env:"DB_TLS"`
+	// ConnectTimeout limits the time to establish a connection.
+	ConnectTimeout time.Duration `json:"connectTimeout,omitempty" env:"DB_CONNECT_TIMEOUT"`
+
+	// Connection pool settings, applied to the underlying sql.DB by Connect.
+	// Zero values keep the database/sql defaults.
+	MaxOpenConns    int           `json:"maxOpenConns,omitempty" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `json:"maxIdleConns,omitempty" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `json:"connMaxLifetime,omitempty" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime,omitempty" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+func (config MysqlDatabaseConfig) JsonString() string {
+	jsonConfig, err := json.Marshal(config)
+	if err != nil {
+		log.Fatal("Can not marshal config: ", err)
+	}
+
+	return string(jsonConfig)
+}
+
+// LoadMysqlDatabaseConfig reads the config from the `DB_*` environment
+// variables, each prefixed with the optional prefix, see utils.LoadEnvConfig.
+func LoadMysqlDatabaseConfig(prefix ...string) (*MysqlDatabaseConfig, error) {
+	config := &MysqlDatabaseConfig{}
+	if err := utils.LoadEnvConfig(config, prefix...); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate returns an error listing every missing required field.
+func (config MysqlDatabaseConfig) Validate() error {
+	return utils.ValidateRequired(config)
+}
+
+func (config MysqlDatabaseConfig) IsValid() bool {
+	return config.Validate() == nil
+}
+
+// Dsn returns a fully qualified MySQL connection string including database name.
+func (config MysqlDatabaseConfig) Dsn() string {
+	return config.dsn(config.Name)
+}
+
+// DsnWithoutDB returns a fully qualified MySQL connection string excluding the database name.
+// This is used for testing operations like dropping or creating a test database.
+func (config MysqlDatabaseConfig) DsnWithoutDB() string {
+	return config.dsn("")
+}
+
+// DsnWithDB returns a fully qualified MySQL connection string for another
+// database on the same server, sharing every other option.
+func (config MysqlDatabaseConfig) DsnWithDB(dbname string) string {
+	return config.dsn(dbname)
+}
+
+func (config MysqlDatabaseConfig) dsn(dbname string) string {
+	dsn := mysql.NewConfig()
+	dsn.User = config.User
+	dsn.Passwd = config.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(config.Host, config.Port)
+	dsn.DBName = dbname
+	dsn.ParseTime = true
+	dsn.TLSConfig = config.Tls
+	dsn.Timeout = config.ConnectTimeout
+
+	charset := config.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	dsn.Params = map[string]string{"charset": charset}
+
+	if config.TimeZone != "" {
+		if location, err := time.LoadLocation(config.TimeZone); err == nil {
+			dsn.Loc = location
+		} else {
+			log.Printf("Warning: unknown time zone '%s', using UTC", config.TimeZone)
+		}
+	}
+
+	return dsn.FormatDSN()
+}
+
+// ConfigurePool applies the pool settings of the config to the sql.DB behind db.
+func (config MysqlDatabaseConfig) ConfigurePool(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+
+	if config.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	return nil
+}
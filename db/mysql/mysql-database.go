@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anoaland/xgo/db/connect"
+	"github.com/anoaland/xgo/db/logger"
+	"github.com/anoaland/xgo/db/replica"
+	"github.com/go-sql-driver/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Connect connects to the database and panics on failure.
+// See ConnectContext for an error-returning variant with retries.
+func Connect(config *MysqlDatabaseConfig, opts ...gorm.Option) *gorm.DB {
+	db, err := ConnectContext(context.Background(), config, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+// ConnectContext connects to the database, retrying according to the
+// connect.Retry option, and configures the connection pool.
+// The returned error is a *connect.ConnectError.
+func ConnectContext(ctx context.Context, config *MysqlDatabaseConfig, opts ...gorm.Option) (*gorm.DB, error) {
+	log := logger.LogFromOpts(opts...)
+
+	dbname := config.Name
+	host := config.Host
+	target := fmt.Sprintf("database '%s' on '%s'", dbname, host)
+	dsn := config.Dsn()
+
+	db, err := connect.Open(ctx, target, func() gorm.Dialector {
+		return gormmysql.Open(dsn)
+	}, classifyError, opts...)
+	if err != nil {
+		log.Error(ctx, "failed to connect database '%s' on '%s'", dbname, host)
+		log.Error(ctx, err.Error())
+		return nil, err
+	}
+
+	if err := config.ConfigurePool(db); err != nil {
+		log.Error(ctx, "failed to configure connection pool of database '%s' on '%s'", dbname, host)
+		connect.Close(db)
+		return nil, err
+	}
+
+	log.Info(ctx, "Successfully connected to database '%s' on '%s'", dbname, host)
+
+	return db, nil
+}
+
+// ConnectWithReplicas connects to the primary with ConnectContext and registers
+// the read replicas, see replica.Register.
+func ConnectWithReplicas(ctx context.Context, primary *MysqlDatabaseConfig, replicas []*MysqlDatabaseConfig, replicaConfig replica.Config, opts ...gorm.Option) (*gorm.DB, error) {
+	db, err := ConnectContext(ctx, primary, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicaList []replica.Replica
+	for _, config := range replicas {
+		replicaList = append(replicaList, replica.Replica{
+			Name:      fmt.Sprintf("%s:%s/%s", config.Host, config.Port, config.Name),
+			Dialector: gormmysql.Open(config.Dsn()),
+			Configure: config.ConfigurePool,
+		})
+	}
+
+	if _, err := replica.Register(db, replicaList, replicaConfig); err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// see: https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+func classifyError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case 1044, 1045:
+		return connect.ErrAuthentication
+	case 1049:
+		return connect.ErrDatabaseNotFound
+	}
+
+	return nil
+}
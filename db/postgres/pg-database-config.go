@@ -4,14 +4,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/anoaland/xgo/utils"
+	"gorm.io/gorm"
 )
 
 type PgDatabaseConfig struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Port     string `json:"port"`
+	Name     string `json:"name" env:"DB_NAME" required:"true"`
+	Host     string `json:"host" env:"DB_HOST" required:"true"`
+	User     string `json:"user" env:"DB_USER" required:"true"`
+	Password string `json:"password" env:"DB_PASSWORD" required:"true"`
+	Port     string `json:"port" env:"DB_PORT" default:"5432" required:"true"`
+
+	// SslMode is one of disable, allow, prefer, require, verify-ca or verify-full.
+	// Optional. Default: "disable".
+	SslMode string `json:"sslMode,omitempty" env:"DB_SSL_MODE"`
+	// SslRootCert is the path of the CA certificate used to verify the server.
+	SslRootCert string `json:"sslRootCert,omitempty" env:"DB_SSL_ROOT_CERT"`
+	// SslCert and SslKey are the paths of the client certificate and its key.
+	SslCert string `json:"sslCert,omitempty" env:"DB_SSL_CERT"`
+	SslKey  string `json:"sslKey,omitempty" env:"DB_SSL_KEY"`
+
+	// Schema sets the search_path of every connection.
+	Schema string `json:"schema,omitempty" env:"DB_SCHEMA"`
+	// TimeZone sets the session time zone, e.g. "UTC" or "Asia/Jakarta".
+	TimeZone string `json:"timeZone,omitempty" env:"DB_TIMEZONE"`
+	// ApplicationName is reported in pg_stat_activity.
+	ApplicationName string `json:"applicationName,omitempty" env:"DB_APPLICATION_NAME"`
+	// ConnectTimeout limits the time to establish a connection, rounded to seconds.
+	ConnectTimeout time.Duration `json:"connectTimeout,omitempty" env:"DB_CONNECT_TIMEOUT"`
+	// StatementTimeout aborts statements running longer, rounded to milliseconds.
+	StatementTimeout time.Duration `json:"statementTimeout,omitempty" env:"DB_STATEMENT_TIMEOUT"`
+
+	// Connection pool settings, applied to the underlying sql.DB by Connect.
+	// Zero values keep the database/sql defaults.
+	MaxOpenConns    int           `json:"maxOpenConns,omitempty" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `json:"maxIdleConns,omitempty" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `json:"connMaxLifetime,omitempty" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime,omitempty" env:"DB_CONN_MAX_IDLE_TIME"`
 }
 
 func (config PgDatabaseConfig) JsonString() string {
@@ -23,31 +57,129 @@ func (config PgDatabaseConfig) JsonString() string {
 	return string(jsonConfig)
 }
 
-func (config PgDatabaseConfig) IsValid() bool {
-	notValid := config.Name == "" || config.Host == "" || config.User == "" || config.Password == "" || config.Port == ""
+// LoadPgDatabaseConfig reads the config from the `DB_*` environment variables,
+// each prefixed with the optional prefix, see utils.LoadEnvConfig.
+func LoadPgDatabaseConfig(prefix ...string) (*PgDatabaseConfig, error) {
+	config := &PgDatabaseConfig{}
+	if err := utils.LoadEnvConfig(config, prefix...); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate returns an error listing every missing required field.
+func (config PgDatabaseConfig) Validate() error {
+	return utils.ValidateRequired(config)
+}
 
-	return !notValid
+func (config PgDatabaseConfig) IsValid() bool {
+	return config.Validate() == nil
 }
 
 // Dsn returns a fully qualified PostgreSQL connection string including database name.
 // This is used for normal database operations.
 func (config PgDatabaseConfig) Dsn() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.Name,
-	)
+	return config.dsn(config.Name)
 }
 
 // Dsn returns a fully qualified PostgreSQL connection string excluding the database name.
 // This is used for testing operations like dropping or creating a test database.
 func (config PgDatabaseConfig) DsnWithoutDB() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s?sslmode=disable",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-	)
+	return config.dsn("")
+}
+
+// DsnWithDB returns a fully qualified PostgreSQL connection string for another
+// database on the same server, sharing every other option.
+func (config PgDatabaseConfig) DsnWithDB(dbname string) string {
+	return config.dsn(dbname)
+}
+
+func (config PgDatabaseConfig) dsn(dbname string) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     net.JoinHostPort(config.Host, config.Port),
+		RawQuery: config.dsnParams().Encode(),
+	}
+
+	if dbname != "" {
+		dsn.Path = "/" + dbname
+	}
+
+	return dsn.String()
+}
+
+func (config PgDatabaseConfig) dsnParams() url.Values {
+	params := url.Values{}
+
+	sslMode := config.SslMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params.Set("sslmode", sslMode)
+
+	if config.SslRootCert != "" {
+		params.Set("sslrootcert", config.SslRootCert)
+	}
+
+	if config.SslCert != "" {
+		params.Set("sslcert", config.SslCert)
+	}
+
+	if config.SslKey != "" {
+		params.Set("sslkey", config.SslKey)
+	}
+
+	if config.Schema != "" {
+		params.Set("search_path", config.Schema)
+	}
+
+	if config.TimeZone != "" {
+		params.Set("timezone", config.TimeZone)
+	}
+
+	if config.ApplicationName != "" {
+		params.Set("application_name", config.ApplicationName)
+	}
+
+	if config.ConnectTimeout > 0 {
+		seconds := int(config.ConnectTimeout.Round(time.Second) / time.Second)
+		if seconds == 0 {
+			seconds = 1
+		}
+		params.Set("connect_timeout", strconv.Itoa(seconds))
+	}
+
+	if config.StatementTimeout > 0 {
+		params.Set("statement_timeout", strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10))
+	}
+
+	return params
+}
+
+// ConfigurePool applies the pool settings of the config to the sql.DB behind db.
+func (config PgDatabaseConfig) ConfigurePool(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	if config.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+
+	if config.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	return nil
 }
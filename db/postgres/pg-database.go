@@ -2,35 +2,102 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/anoaland/xgo/db/connect"
 	"github.com/anoaland/xgo/db/logger"
+	"github.com/anoaland/xgo/db/replica"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Connect connects to the database and panics on failure.
+// See ConnectContext for an error-returning variant with retries.
 func Connect(config *PgDatabaseConfig, opts ...gorm.Option) *gorm.DB {
+	db, err := ConnectContext(context.Background(), config, opts...)
+	if err != nil {
+		panic(err)
+	}
 
+	return db
+}
+
+// ConnectContext connects to the database, retrying according to the
+// connect.Retry option, and configures the connection pool.
+// The returned error is a *connect.ConnectError.
+func ConnectContext(ctx context.Context, config *PgDatabaseConfig, opts ...gorm.Option) (*gorm.DB, error) {
 	dbname := config.Name
 	host := config.Host
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s",
-		host,
-		config.User,
-		config.Password,
-		dbname,
-		config.Port,
-	)
+	target := fmt.Sprintf("database '%s' on '%s'", dbname, host)
+	dsn := config.Dsn()
+
+	db, err := connect.Open(ctx, target, func() gorm.Dialector {
+		return postgres.Open(dsn)
+	}, classifyError, opts...)
 
 	log := logger.LogFromOpts(opts...)
-	db, err := gorm.Open(postgres.Open(dsn), opts...)
 
 	if err != nil {
-		log.Error(context.Background(), "failed to connect database '%s' on '%s'", dbname, host)
-		log.Error(context.Background(), err.Error())
-		panic(err)
+		log.Error(ctx, "failed to connect database '%s' on '%s'", dbname, host)
+		log.Error(ctx, err.Error())
+		return nil, err
 	}
 
-	log.Info(context.Background(), "Successfully connected to database '%s' on '%s'", dbname, host)
+	if err := config.ConfigurePool(db); err != nil {
+		log.Error(ctx, "failed to configure connection pool of database '%s' on '%s'", dbname, host)
+		connect.Close(db)
+		return nil, err
+	}
 
-	return db
+	log.Info(ctx, "Successfully connected to database '%s' on '%s'", dbname, host)
+
+	return db, nil
+}
+
+// ConnectWithReplicas connects to the primary with ConnectContext and registers
+// the read replicas: reads are routed to the replicas according to
+// replicaConfig, while writes and transactions go to the primary.
+// Use replica.UsePrimary to read from the primary.
+func ConnectWithReplicas(ctx context.Context, primary *PgDatabaseConfig, replicas []*PgDatabaseConfig, replicaConfig replica.Config, opts ...gorm.Option) (*gorm.DB, error) {
+	db, err := ConnectContext(ctx, primary, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicaList []replica.Replica
+	for _, config := range replicas {
+		replicaList = append(replicaList, replica.Replica{
+			Name:      fmt.Sprintf("%s:%s/%s", config.Host, config.Port, config.Name),
+			Dialector: postgres.Open(config.Dsn()),
+			Configure: config.ConfigurePool,
+		})
+	}
+
+	if _, err := replica.Register(db, replicaList, replicaConfig); err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// see: https://www.postgresql.org/docs/current/errcodes-appendix.html
+func classifyError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	switch pgErr.Code {
+	case "28000", "28P01":
+		return connect.ErrAuthentication
+	case "3D000":
+		return connect.ErrDatabaseNotFound
+	}
+
+	return nil
 }
@@ -0,0 +1,366 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	pluginName    = "xgo:replica"
+	usePrimaryKey = "xgo:replica:use_primary"
+)
+
+type Policy string
+
+const (
+	// RoundRobin spreads reads evenly over the healthy replicas.
+	RoundRobin Policy = "round-robin"
+	// LeastLatency sends reads to the healthy replica with the lowest ping latency.
+	LeastLatency Policy = "least-latency"
+)
+
+type Config struct {
+	// Policy selects the replica of every read.
+	// Optional. Default: RoundRobin.
+	Policy Policy
+
+	// HealthCheckInterval is the time between two pings of every replica.
+	// Optional. Default: 10s. A negative value disables health checks.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout limits every ping.
+	// Optional. Default: 2s.
+	HealthCheckTimeout time.Duration
+
+	// MaxFailures is the number of consecutive failed pings after which a
+	// replica is evicted, until a ping succeeds again.
+	// Optional. Default: 2.
+	MaxFailures int
+}
+
+// Replica describes a read replica to register.
+type Replica struct {
+	// Name identifies the replica in logs, e.g. its host.
+	Name      string
+	Dialector gorm.Dialector
+	// Configure is called with the opened replica, e.g. to set its pool.
+	// Optional.
+	Configure func(db *gorm.DB) error
+}
+
+// Resolver routes the reads of a gorm.DB to its replicas, while writes,
+// transactions, locking reads and statements marked with UsePrimary go to
+// the primary. When no replica is healthy, reads fall back to the primary.
+type Resolver struct {
+	config   Config
+	db       *gorm.DB
+	replicas []*replicaPool
+	next     atomic.Uint64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type replicaPool struct {
+	name     string
+	db       *gorm.DB
+	pool     gorm.ConnPool
+	healthy  atomic.Bool
+	failures atomic.Int32
+	latency  atomic.Int64
+}
+
+// Register opens the replicas and installs a Resolver on db.
+// The Resolver can be retrieved later with From.
+func Register(db *gorm.DB, replicas []Replica, config ...Config) (*Resolver, error) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	if cfg.Policy == "" {
+		cfg.Policy = RoundRobin
+	}
+
+	if cfg.HealthCheckInterval == 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = 2 * time.Second
+	}
+
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 2
+	}
+
+	r := &Resolver{config: cfg, stop: make(chan struct{})}
+
+	for _, replica := range replicas {
+		pool, err := openReplica(db, replica)
+		if err != nil {
+			r.closeReplicas()
+			return nil, err
+		}
+		r.replicas = append(r.replicas, pool)
+	}
+
+	if err := db.Use(r); err != nil {
+		r.closeReplicas()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func openReplica(db *gorm.DB, replica Replica) (*replicaPool, error) {
+	replicaDB, err := gorm.Open(replica.Dialector, &gorm.Config{
+		Logger:               db.Config.Logger,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica '%s': %w", replica.Name, err)
+	}
+
+	if replica.Configure != nil {
+		if err := replica.Configure(replicaDB); err != nil {
+			if sqlDB, err := replicaDB.DB(); err == nil {
+				sqlDB.Close()
+			}
+			return nil, fmt.Errorf("failed to configure replica '%s': %w", replica.Name, err)
+		}
+	}
+
+	pool := &replicaPool{name: replica.Name, db: replicaDB, pool: replicaDB.ConnPool}
+	pool.healthy.Store(true)
+
+	return pool, nil
+}
+
+// From returns the Resolver registered on db, or nil.
+func From(db *gorm.DB) *Resolver {
+	if plugin, ok := db.Config.Plugins[pluginName]; ok {
+		return plugin.(*Resolver)
+	}
+
+	return nil
+}
+
+// UsePrimary forces the statements of the returned session to the primary,
+// e.g. to read your own writes.
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Set(usePrimaryKey, true)
+}
+
+func (r *Resolver) Name() string {
+	return pluginName
+}
+
+func (r *Resolver) Initialize(db *gorm.DB) error {
+	r.db = db
+
+	callbacks := []error{
+		db.Callback().Query().Before("*").Register(pluginName, r.switchRead),
+		db.Callback().Row().Before("*").Register(pluginName, r.switchRead),
+		db.Callback().Raw().Before("*").Register(pluginName, r.switchGuess),
+		db.Callback().Create().Before("*").Register(pluginName, r.switchPrimary),
+		db.Callback().Update().Before("*").Register(pluginName, r.switchPrimary),
+		db.Callback().Delete().Before("*").Register(pluginName, r.switchPrimary),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+
+	r.checkHealth()
+
+	if r.config.HealthCheckInterval > 0 {
+		go r.runHealthChecks()
+	}
+
+	return nil
+}
+
+// Stop stops the health checks and closes the replicas.
+func (r *Resolver) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.closeReplicas()
+	})
+}
+
+// Healthy returns the names of the replicas currently receiving reads.
+func (r *Resolver) Healthy() []string {
+	var names []string
+	for _, replica := range r.replicas {
+		if replica.healthy.Load() {
+			names = append(names, replica.name)
+		}
+	}
+
+	return names
+}
+
+func (r *Resolver) closeReplicas() {
+	for _, replica := range r.replicas {
+		if sqlDB, err := replica.db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+}
+
+func (r *Resolver) switchPrimary(db *gorm.DB) {
+	if !r.isPinned(db.Statement.ConnPool) {
+		db.Statement.ConnPool = r.db.ConnPool
+	}
+}
+
+func (r *Resolver) switchRead(db *gorm.DB) {
+	if r.isPinned(db.Statement.ConnPool) {
+		return
+	}
+
+	if db.Statement.SQL.Len() > 0 {
+		r.switchGuess(db)
+		return
+	}
+
+	if _, locking := db.Statement.Clauses["FOR"]; locking || usesPrimary(db) {
+		db.Statement.ConnPool = r.db.ConnPool
+		return
+	}
+
+	db.Statement.ConnPool = r.resolve()
+}
+
+func (r *Resolver) switchGuess(db *gorm.DB) {
+	if r.isPinned(db.Statement.ConnPool) {
+		return
+	}
+
+	sql := strings.TrimSpace(db.Statement.SQL.String())
+	isRead := len(sql) > 6 && strings.EqualFold(sql[:6], "SELECT") &&
+		!strings.HasSuffix(strings.ToUpper(sql), "FOR UPDATE")
+
+	if isRead && !usesPrimary(db) {
+		db.Statement.ConnPool = r.resolve()
+	} else {
+		db.Statement.ConnPool = r.db.ConnPool
+	}
+}
+
+// resolve picks a healthy replica according to the policy, or the primary.
+func (r *Resolver) resolve() gorm.ConnPool {
+	var candidates []*replicaPool
+	for _, replica := range r.replicas {
+		if replica.healthy.Load() {
+			candidates = append(candidates, replica)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return r.db.ConnPool
+	}
+
+	if r.config.Policy == LeastLatency {
+		best := candidates[0]
+		for _, candidate := range candidates[1:] {
+			if candidate.latency.Load() < best.latency.Load() {
+				best = candidate
+			}
+		}
+		return best.pool
+	}
+
+	return candidates[r.next.Add(1)%uint64(len(candidates))].pool
+}
+
+func (r *Resolver) runHealthChecks() {
+	ticker := time.NewTicker(r.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.checkHealth()
+		}
+	}
+}
+
+func (r *Resolver) checkHealth() {
+	for _, replica := range r.replicas {
+		r.checkReplica(replica)
+	}
+}
+
+func (r *Resolver) checkReplica(replica *replicaPool) {
+	ctx := context.Background()
+
+	sqlDB, err := replica.db.DB()
+	if err != nil {
+		return
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, r.config.HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err = sqlDB.PingContext(pingCtx)
+	latency := time.Since(start)
+
+	if err != nil {
+		failures := replica.failures.Add(1)
+		if int(failures) >= r.config.MaxFailures && replica.healthy.Swap(false) {
+			r.db.Logger.Warn(ctx, "Evicting replica '%s' after %d failed health checks: %v", replica.name, failures, err)
+		}
+		return
+	}
+
+	replica.failures.Store(0)
+
+	// exponentially weighted moving average, smoothing out spikes
+	previous := replica.latency.Load()
+	if previous == 0 {
+		replica.latency.Store(int64(latency))
+	} else {
+		replica.latency.Store((previous*7 + int64(latency)*3) / 10)
+	}
+
+	if !replica.healthy.Swap(true) {
+		r.db.Logger.Info(ctx, "Replica '%s' is healthy again", replica.name)
+	}
+}
+
+func usesPrimary(db *gorm.DB) bool {
+	usePrimary, ok := db.Get(usePrimaryKey)
+	return ok && usePrimary == true
+}
+
+// isPinned reports whether connPool is a transaction, a connection
+// pinned with gorm.DB.Connection or any other pool than the primary's, e.g. a
+// recording one, which must be kept.
+func (r *Resolver) isPinned(connPool gorm.ConnPool) bool {
+	switch connPool.(type) {
+	case gorm.TxCommitter, *sql.Conn:
+		return true
+	}
+
+	return unwrapPrepared(connPool) != unwrapPrepared(r.db.ConnPool)
+}
+
+func unwrapPrepared(connPool gorm.ConnPool) gorm.ConnPool {
+	if prepared, ok := connPool.(*gorm.PreparedStmtDB); ok {
+		return prepared.ConnPool
+	}
+
+	return connPool
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/anoaland/xgo/utils"
+	"gorm.io/gorm"
+)
+
+type SqliteDatabaseConfig struct {
+	// Path of the database file. Empty or ":memory:" opens an in-memory database.
+	Path string `json:"path" env:"DB_PATH"`
+	// Name shares a named in-memory database between connections of the process.
+	// Optional. Without it every Connect opens its own in-memory database.
+	Name string `json:"name" env:"DB_NAME"`
+
+	// JournalMode of a file database, e.g. WAL, DELETE or TRUNCATE.
+	// Optional. Default: "WAL".
+	JournalMode string `json:"journalMode,omitempty" env:"DB_JOURNAL_MODE"`
+	// DisableForeignKeys turns off the enforcement of foreign keys, on by default.
+	DisableForeignKeys bool `json:"disableForeignKeys,omitempty" env:"DB_DISABLE_FOREIGN_KEYS"`
+	// BusyTimeout is the time to wait for a locked database.
+	// Optional. Default: 5s.
+	BusyTimeout time.Duration `json:"busyTimeout,omitempty" env:"DB_BUSY_TIMEOUT"`
+}
+
+func (config SqliteDatabaseConfig) JsonString() string {
+	jsonConfig, err := json.Marshal(config)
+	if err != nil {
+		log.Fatal("Can not marshal config: ", err)
+	}
+
+	return string(jsonConfig)
+}
+
+// LoadSqliteDatabaseConfig reads the config from the `DB_*` environment
+// variables, each prefixed with the optional prefix, see utils.LoadEnvConfig.
+func LoadSqliteDatabaseConfig(prefix ...string) (*SqliteDatabaseConfig, error) {
+	config := &SqliteDatabaseConfig{}
+	if err := utils.LoadEnvConfig(config, prefix...); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate returns an error listing every missing required field.
+func (config SqliteDatabaseConfig) Validate() error {
+	return utils.ValidateRequired(config)
+}
+
+func (config SqliteDatabaseConfig) IsValid() bool {
+	return config.Validate() == nil
+}
+
+// IsInMemory reports whether the config opens an in-memory database.
+func (config SqliteDatabaseConfig) IsInMemory() bool {
+	return config.Path == "" || config.Path == ":memory:"
+}
+
+// Dsn returns the SQLite connection string with the pragmas of the config.
+func (config SqliteDatabaseConfig) Dsn() string {
+	params := url.Values{}
+
+	var path string
+	if config.IsInMemory() {
+		path = "file::memory:"
+		if config.Name != "" {
+			path = "file:" + config.Name
+			params.Set("mode", "memory")
+			params.Set("cache", "shared")
+		}
+	} else {
+		path = "file:" + config.Path
+
+		journalMode := config.JournalMode
+		if journalMode == "" {
+			journalMode = "WAL"
+		}
+		params.Set("_journal_mode", journalMode)
+	}
+
+	if !config.DisableForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+
+	busyTimeout := config.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = 5 * time.Second
+	}
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+
+	return fmt.Sprintf("%s?%s", path, params.Encode())
+}
+
+// ConfigurePool keeps a single connection to an unnamed in-memory database,
+// every new connection would otherwise open an empty database.
+func (config SqliteDatabaseConfig) ConfigurePool(db *gorm.DB) error {
+	if !config.IsInMemory() || config.Name != "" {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	sqlDB.SetConnMaxIdleTime(0)
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anoaland/xgo/db/connect"
+	"github.com/anoaland/xgo/db/logger"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Connect connects to the database and panics on failure.
+// See ConnectContext for an error-returning variant.
+func Connect(config *SqliteDatabaseConfig, opts ...gorm.Option) *gorm.DB {
+	db, err := ConnectContext(context.Background(), config, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+// ConnectContext connects to the database, retrying according to the
+// connect.Retry option. The returned error is a *connect.ConnectError.
+func ConnectContext(ctx context.Context, config *SqliteDatabaseConfig, opts ...gorm.Option) (*gorm.DB, error) {
+	log := logger.LogFromOpts(opts...)
+
+	name := config.Path
+	if config.IsInMemory() {
+		name = ":memory:" + config.Name
+	}
+	target := fmt.Sprintf("database '%s'", name)
+	dsn := config.Dsn()
+
+	db, err := connect.Open(ctx, target, func() gorm.Dialector {
+		return sqlite.Open(dsn)
+	}, nil, opts...)
+	if err != nil {
+		log.Error(ctx, "failed to connect database '%s'", name)
+		log.Error(ctx, err.Error())
+		return nil, err
+	}
+
+	if err := config.ConfigurePool(db); err != nil {
+		log.Error(ctx, "failed to configure connection pool of database '%s'", name)
+		connect.Close(db)
+		return nil, err
+	}
+
+	log.Info(ctx, "Successfully connected to database '%s'", name)
+
+	return db, nil
+}
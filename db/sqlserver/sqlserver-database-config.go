@@ -3,14 +3,16 @@ package database
 import (
 	"encoding/json"
 	"log"
+
+	"github.com/anoaland/xgo/utils"
 )
 
 type SqlServerDatabaseConfig struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Port     string `json:"port"`
+	Name     string `json:"name" env:"DB_NAME" required:"true"`
+	Host     string `json:"host" env:"DB_HOST" required:"true"`
+	User     string `json:"user" env:"DB_USER" required:"true"`
+	Password string `json:"password" env:"DB_PASSWORD" required:"true"`
+	Port     string `json:"port" env:"DB_PORT" default:"1433" required:"true"`
 }
 
 func (config SqlServerDatabaseConfig) JsonString() string {
@@ -22,8 +24,22 @@ func (config SqlServerDatabaseConfig) JsonString() string {
 	return string(jsonConfig)
 }
 
-func (config SqlServerDatabaseConfig) IsValid() bool {
-	notValid := config.Name == "" || config.Host == "" || config.User == "" || config.Password == "" || config.Port == ""
+// LoadSqlServerDatabaseConfig reads the config from the `DB_*` environment
+// variables, each prefixed with the optional prefix, see utils.LoadEnvConfig.
+func LoadSqlServerDatabaseConfig(prefix ...string) (*SqlServerDatabaseConfig, error) {
+	config := &SqlServerDatabaseConfig{}
+	if err := utils.LoadEnvConfig(config, prefix...); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate returns an error listing every missing required field.
+func (config SqlServerDatabaseConfig) Validate() error {
+	return utils.ValidateRequired(config)
+}
 
-	return !notValid
+func (config SqlServerDatabaseConfig) IsValid() bool {
+	return config.Validate() == nil
 }
@@ -2,30 +2,78 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/anoaland/xgo/db/connect"
 	"github.com/anoaland/xgo/db/logger"
+	"github.com/anoaland/xgo/db/replica"
+	mssql "github.com/microsoft/go-mssqldb"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 )
 
+// Connect connects to the database and panics on failure.
+// See ConnectContext for an error-returning variant with retries.
 func Connect(config *SqlServerDatabaseConfig, opts ...gorm.Option) *gorm.DB {
+	db, err := ConnectContext(context.Background(), config, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return db
+}
+
+// ConnectContext connects to the database, retrying according to the
+// connect.Retry option. The returned error is a *connect.ConnectError.
+func ConnectContext(ctx context.Context, config *SqlServerDatabaseConfig, opts ...gorm.Option) (*gorm.DB, error) {
 	log := logger.LogFromOpts(opts...)
 
 	dsn := config.Dsn(nil)
 	dbname := config.Name
 	host := config.Host
+	target := fmt.Sprintf("database '%s' on '%s'", dbname, host)
 
-	db, err := gorm.Open(sqlserver.Open(dsn), opts...)
+	db, err := connect.Open(ctx, target, func() gorm.Dialector {
+		return sqlserver.Open(dsn)
+	}, classifyError, opts...)
 	if err != nil {
-		log.Error(context.Background(), fmt.Sprintf("failed to connect database '%s' on '%s'", dbname, host))
-		log.Error(context.Background(), err.Error())
-		panic(err)
+		log.Error(ctx, fmt.Sprintf("failed to connect database '%s' on '%s'", dbname, host))
+		log.Error(ctx, err.Error())
+		return nil, err
 	}
 
-	log.Info(context.Background(), fmt.Sprintf("Successfully connected to database '%s' on '%s'", dbname, host))
+	log.Info(ctx, fmt.Sprintf("Successfully connected to database '%s' on '%s'", dbname, host))
 
-	return db
+	return db, nil
+}
+
+// ConnectWithReplicas connects to the primary with ConnectContext and registers
+// the read replicas: reads are routed to the replicas according to
+// replicaConfig, while writes and transactions go to the primary.
+// Use replica.UsePrimary to read from the primary.
+func ConnectWithReplicas(ctx context.Context, primary *SqlServerDatabaseConfig, replicas []*SqlServerDatabaseConfig, replicaConfig replica.Config, opts ...gorm.Option) (*gorm.DB, error) {
+	db, err := ConnectContext(ctx, primary, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicaList []replica.Replica
+	for _, config := range replicas {
+		replicaList = append(replicaList, replica.Replica{
+			Name:      fmt.Sprintf("%s:%s/%s", config.Host, config.Port, config.Name),
+			Dialector: sqlserver.Open(config.Dsn(nil)),
+		})
+	}
+
+	if _, err := replica.Register(db, replicaList, replicaConfig); err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return nil, err
+	}
+
+	return db, nil
 }
 
 func (config *SqlServerDatabaseConfig) Dsn(dbname *string) string {
@@ -46,3 +94,33 @@ func (config *SqlServerDatabaseConfig) Dsn(dbname *string) string {
 
 	return dsn
 }
+
+// DsnWithoutDB returns a connection string to the default database of the
+// login, used for operations like creating or dropping a test database.
+func (config *SqlServerDatabaseConfig) DsnWithoutDB() string {
+	dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s",
+		config.User,
+		config.Password,
+		config.Host,
+		config.Port,
+	)
+
+	return dsn
+}
+
+// see: https://learn.microsoft.com/en-us/sql/relational-databases/errors-events/database-engine-events-and-errors
+func classifyError(err error) error {
+	var sqlErr mssql.Error
+	if !errors.As(err, &sqlErr) {
+		return nil
+	}
+
+	switch sqlErr.Number {
+	case 18456:
+		return connect.ErrAuthentication
+	case 4060:
+		return connect.ErrDatabaseNotFound
+	}
+
+	return nil
+}
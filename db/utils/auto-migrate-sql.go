@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DdlStatement is a statement AutoMigrate would run.
+type DdlStatement struct {
+	// Table of the migrated model, the statement may also target a join table.
+	Table string
+	// Kind is the leading keywords, e.g. "CREATE TABLE", "ALTER TABLE" or "CREATE INDEX".
+	Kind string
+	SQL  string
+}
+
+// AutoMigrateSql returns the statements AutoMigrate would run for the models
+// against the current schema of db, using the migrator of its dialect.
+// The schema is read but no statement is executed, and the config of db is
+// left untouched.
+func AutoMigrateSql(db *gorm.DB, dst ...interface{}) ([]DdlStatement, error) {
+	recorder := &ddlRecorder{ConnPool: unwrapPrepared(db.Statement.ConnPool)}
+
+	session := db.Session(&gorm.Session{NewDB: true, SkipDefaultTransaction: true})
+	session.Statement.ConnPool = recorder
+
+	var statements []DdlStatement
+	// since nothing is created, a join table or a model migrated through an
+	// association of a previous model is generated again
+	seen := map[string]bool{}
+	for _, model := range dst {
+		stmt := &gorm.Statement{DB: session}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+
+		if err := session.Migrator().AutoMigrate(model); err != nil {
+			return nil, fmt.Errorf("failed to generate the migration of '%s': %w", stmt.Table, err)
+		}
+
+		for _, sql := range recorder.take() {
+			if seen[sql] {
+				continue
+			}
+			seen[sql] = true
+			statements = append(statements, DdlStatement{Table: stmt.Table, Kind: ddlKind(sql), SQL: sql})
+		}
+	}
+
+	return statements, nil
+}
+
+// FormatDdl returns the statements as a SQL script, one per line.
+func FormatDdl(statements []DdlStatement) string {
+	var b strings.Builder
+	for _, statement := range statements {
+		b.WriteString(strings.TrimSuffix(strings.TrimSpace(statement.SQL), ";"))
+		b.WriteString(";\n")
+	}
+
+	return b.String()
+}
+
+// WriteMigrationFile writes the statements to `<dir>/<timestamp>_<name>.sql`,
+// the versioned layout of atlas and the migrate package, and returns its path.
+// Nothing is written when there are no statements. The atlas.sum file, if any,
+// must be rebuilt afterwards, e.g. with `go run ./atlas hash`.
+func WriteMigrationFile(dir string, name string, statements []DdlStatement) (string, error) {
+	if len(statements) == 0 {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.sql", time.Now().UTC().Format("20060102150405"), name))
+	if err := os.WriteFile(path, []byte(FormatDdl(statements)), 0o644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+var ddlKeywords = regexp.MustCompile(`(?i)^\s*((CREATE|ALTER|DROP|COMMENT|EXEC)(\s+(UNIQUE|OR\s+REPLACE|TEMPORARY))?(\s+(TABLE|INDEX|VIEW|TYPE|SCHEMA|SEQUENCE|CONSTRAINT|ON))?)\b`)
+
+func ddlKind(sql string) string {
+	match := ddlKeywords.FindStringSubmatch(sql)
+	if match == nil {
+		return ""
+	}
+
+	return strings.ToUpper(strings.Join(strings.Fields(match[1]), " "))
+}
+
+// ddlRecorder records the statements executed through it instead of running
+// them, while queries reach the database to read the current schema.
+type ddlRecorder struct {
+	gorm.ConnPool
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *ddlRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if len(args) > 0 {
+		return nil, fmt.Errorf("unexpected bound values in migration statement: %s", query)
+	}
+
+	r.mu.Lock()
+	r.statements = append(r.statements, query)
+	r.mu.Unlock()
+
+	return driver.RowsAffected(0), nil
+}
+
+// BeginTx lets the migrators of SQLite and others run their statements in a
+// transaction, recorded as well.
+func (r *ddlRecorder) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return &ddlRecorderTx{r}, nil
+}
+
+func (r *ddlRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statements := r.statements
+	r.statements = nil
+
+	return statements
+}
+
+type ddlRecorderTx struct {
+	*ddlRecorder
+}
+
+func (tx *ddlRecorderTx) Commit() error {
+	return nil
+}
+
+func (tx *ddlRecorderTx) Rollback() error {
+	return nil
+}
+
+func unwrapPrepared(connPool gorm.ConnPool) gorm.ConnPool {
+	if prepared, ok := connPool.(*gorm.PreparedStmtDB); ok {
+		return prepared.ConnPool
+	}
+
+	return connPool
+}
@@ -0,0 +1,84 @@
+package dto
+
+import (
+	"strings"
+)
+
+// CursorPagination pages with opaque cursors instead of offsets, which stays
+// fast on large tables. See repository.KeysetPaginate.
+type CursorPagination struct {
+	Limit int `json:"limit" query:"limit"`
+	// Cursor is the NextCursor or PrevCursor of a previous page, empty for the first page.
+	Cursor    string `json:"-" query:"cursor"`
+	SortBy    string `json:"-" query:"sortBy"`
+	SortOrder string `json:"-" query:"sortOrder"`
+	// SkipCount skips the `COUNT(*)` of TotalData.
+	SkipCount bool `json:"-" query:"skipCount"`
+
+	NextCursor string      `json:"nextCursor,omitempty"`
+	PrevCursor string      `json:"prevCursor,omitempty"`
+	TotalData  *int64      `json:"totalData,omitempty"`
+	Rows       interface{} `json:"-"`
+
+	// sorts are the sorts validated by Allow, by column.
+	sorts   []SortField
+	allowed bool
+}
+
+// GetLimit returns the page size, 10 by default and at most MaxLimit.
+func (p *CursorPagination) GetLimit() int {
+	if p.Limit <= 0 {
+		p.Limit = 10
+	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
+	return p.Limit
+}
+
+// Allow validates the sort of p against the whitelist of the endpoint and
+// returns a bad request XgoError listing the sortable fields when it is
+// invalid. The sort fields are then replaced by their columns. A sorted
+// pagination must be allowed, see AllowedSort.
+func (p *CursorPagination) Allow(whitelist Whitelist) error {
+	sorts := p.GetSort()
+	if _, err := whitelist.OrderBy(sorts); err != nil {
+		return err
+	}
+
+	fields := make([]string, len(sorts))
+	allowed := make([]SortField, len(sorts))
+	for i, sort := range sorts {
+		column := whitelist[sort.Field].Column
+		if dot := strings.LastIndex(column, "."); dot >= 0 {
+			column = column[dot+1:]
+		}
+
+		allowed[i] = SortField{Field: column, Desc: sort.Desc}
+		if sort.Desc {
+			column = "-" + column
+		}
+		fields[i] = column
+	}
+
+	p.SortBy, p.SortOrder = strings.Join(fields, ","), ""
+	p.sorts, p.allowed = allowed, true
+
+	return nil
+}
+
+// AllowedSort returns the sort validated by Allow, by column, and false when p
+// has a sort which Allow did not validate. Without sort, the rows are ordered
+// by primary key.
+func (p *CursorPagination) AllowedSort() ([]SortField, bool) {
+	if p.allowed {
+		return p.sorts, true
+	}
+
+	return nil, len(p.GetSort()) == 0
+}
+
+// GetSort returns the sort fields, see ParseSort.
+func (p *CursorPagination) GetSort() []SortField {
+	return ParseSort(p.SortBy, p.SortOrder)
+}
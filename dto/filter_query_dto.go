@@ -0,0 +1,314 @@
+package dto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseFilterQuery parses the filters of a query string, either
+//
+//	filter[status]=active&filter[createdAt][gte]=2024-01-01&filter[role][in]=admin,owner
+//
+// or an RSQL expression, see ParseRsql:
+//
+//	filter=status==active;createdAt=ge=2024-01-01
+//
+// The values are strings, or []string for OpIn, converted to the types of the
+// fields by WhitelistField.Coerce. A malformed filter returns a bad request
+// XgoError.
+func ParseFilterQuery(query map[string]string) ([]Filter, error) {
+	var filters []Filter
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := query[key]
+
+		if key == "filter" {
+			rsql, err := ParseRsql(value)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, rsql...)
+			continue
+		}
+
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+
+		field, operator, err := parseFilterKey(key)
+		if err != nil {
+			return nil, badRequest("E_PAGINATION_FILTER", err)
+		}
+
+		filters = append(filters, newFilter(field, operator, value))
+	}
+
+	return filters, nil
+}
+
+// parseFilterKey parses `filter[field]` and `filter[field][operator]`.
+func parseFilterKey(key string) (string, FilterOperator, error) {
+	rest := strings.TrimPrefix(key, "filter")
+
+	var parts []string
+	for len(rest) > 0 {
+		end := strings.Index(rest, "]")
+		if rest[0] != '[' || end < 0 {
+			return "", "", fmt.Errorf("malformed filter '%s'", key)
+		}
+		parts = append(parts, rest[1:end])
+		rest = rest[end+1:]
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return parts[0], OpEq, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], FilterOperator(strings.ToLower(parts[1])), nil
+	}
+
+	return "", "", fmt.Errorf("malformed filter '%s'", key)
+}
+
+func newFilter(field string, operator FilterOperator, value string) Filter {
+	if operator != OpIn {
+		return Filter{Field: field, Operator: operator, Value: value}
+	}
+
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+
+	return Filter{Field: field, Operator: operator, Value: values}
+}
+
+var rsqlOperators = []struct {
+	token    string
+	operator FilterOperator
+}{
+	{"==", OpEq},
+	{"!=", OpNe},
+	{"=gt=", OpGt},
+	{"=ge=", OpGte},
+	{"=lt=", OpLt},
+	{"=le=", OpLte},
+	{"=in=", OpIn},
+	{"=like=", OpLike},
+	{">=", OpGte},
+	{"<=", OpLte},
+	{">", OpGt},
+	{"<", OpLt},
+}
+
+// ParseRsql parses an RSQL expression: comparisons `field<operator>value`,
+// joined with `;` (and) or `,` (or) and grouped with parentheses, e.g.
+// `status==active;(role=in=(admin,owner),createdAt=ge=2024-01-01)`.
+// Operators are ==, !=, =gt= (>), =ge= (>=), =lt= (<), =le= (<=), =in= and
+// =like=. Values containing reserved characters are quoted with ' or ".
+func ParseRsql(expression string) ([]Filter, error) {
+	p := &rsqlParser{input: expression}
+
+	groups, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = p.errorf("unexpected '%c'", p.input[p.pos])
+	}
+	if err != nil {
+		return nil, badRequest("E_PAGINATION_FILTER", err)
+	}
+
+	if len(groups) == 1 {
+		return groups[0], nil
+	}
+
+	return []Filter{{Or: groups}}, nil
+}
+
+type rsqlParser struct {
+	input string
+	pos   int
+}
+
+func (p *rsqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid filter at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *rsqlParser) done() bool {
+	p.skipSpaces()
+	return p.pos >= len(p.input)
+}
+
+func (p *rsqlParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *rsqlParser) consume(c byte) bool {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// parseOr returns the conjunctions of a disjunction.
+func (p *rsqlParser) parseOr() ([][]Filter, error) {
+	var groups [][]Filter
+	for {
+		and, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, and)
+
+		if !p.consume(',') {
+			return groups, nil
+		}
+	}
+}
+
+func (p *rsqlParser) parseAnd() ([]Filter, error) {
+	var filters []Filter
+	for {
+		filter, err := p.parseConstraint()
+		if err != nil {
+			return nil, err
+		}
+
+		// flatten nested conjunctions
+		if len(filter.Or) == 1 {
+			filters = append(filters, filter.Or[0]...)
+		} else {
+			filters = append(filters, filter)
+		}
+
+		if !p.consume(';') {
+			return filters, nil
+		}
+	}
+}
+
+func (p *rsqlParser) parseConstraint() (Filter, error) {
+	if p.consume('(') {
+		groups, err := p.parseOr()
+		if err != nil {
+			return Filter{}, err
+		}
+
+		if !p.consume(')') {
+			return Filter{}, p.errorf("missing ')'")
+		}
+
+		return Filter{Or: groups}, nil
+	}
+
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune("=!<>();, '\"", rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	field := p.input[start:p.pos]
+	if field == "" {
+		return Filter{}, p.errorf("missing field")
+	}
+
+	operator, ok := p.parseOperator()
+	if !ok {
+		return Filter{}, p.errorf("unknown operator after '%s'", field)
+	}
+
+	if operator == OpIn {
+		if !p.consume('(') {
+			return Filter{}, p.errorf("=in= expects a list, e.g. (a,b)")
+		}
+
+		var values []string
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return Filter{}, err
+			}
+			values = append(values, value)
+
+			if p.consume(')') {
+				break
+			}
+
+			if !p.consume(',') {
+				return Filter{}, p.errorf("missing ')'")
+			}
+		}
+
+		return Filter{Field: field, Operator: operator, Value: values}, nil
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return Filter{}, err
+	}
+
+	return Filter{Field: field, Operator: operator, Value: value}, nil
+}
+
+func (p *rsqlParser) parseOperator() (FilterOperator, bool) {
+	p.skipSpaces()
+	for _, candidate := range rsqlOperators {
+		if strings.HasPrefix(p.input[p.pos:], candidate.token) {
+			p.pos += len(candidate.token)
+			return candidate.operator, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *rsqlParser) parseValue() (string, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return "", p.errorf("missing value")
+	}
+
+	if quote := p.input[p.pos]; quote == '\'' || quote == '"' {
+		var value strings.Builder
+		for p.pos++; p.pos < len(p.input); p.pos++ {
+			c := p.input[p.pos]
+			if c == '\\' && p.pos+1 < len(p.input) {
+				p.pos++
+				value.WriteByte(p.input[p.pos])
+				continue
+			}
+
+			if c == quote {
+				p.pos++
+				return value.String(), nil
+			}
+
+			value.WriteByte(c)
+		}
+
+		return "", p.errorf("unterminated string")
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && !strings.ContainsRune("();,", rune(p.input[p.pos])) {
+		p.pos++
+	}
+
+	value := strings.TrimSpace(p.input[start:p.pos])
+	if value == "" {
+		return "", p.errorf("missing value")
+	}
+
+	return value, nil
+}
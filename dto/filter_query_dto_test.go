@@ -0,0 +1,206 @@
+package dto
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	xgoErrors "github.com/anoaland/xgo/errors"
+)
+
+func expectBadRequest(t *testing.T, err error) {
+	t.Helper()
+
+	var xgoErr *xgoErrors.XgoError
+	if !errors.As(err, &xgoErr) || xgoErr.HttpErrorCode != 400 || xgoErr.Part != "E_PAGINATION_FILTER" {
+		t.Fatalf("expected a bad request XgoError, got %v", err)
+	}
+}
+
+func TestParseRsql(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []Filter
+	}{
+		{"equal", "status==active", []Filter{{Field: "status", Operator: OpEq, Value: "active"}}},
+		{"not equal", "status!=active", []Filter{{Field: "status", Operator: OpNe, Value: "active"}}},
+		{"greater", "age=gt=18", []Filter{{Field: "age", Operator: OpGt, Value: "18"}}},
+		{"greater or equal", "age=ge=18", []Filter{{Field: "age", Operator: OpGte, Value: "18"}}},
+		{"less", "age=lt=18", []Filter{{Field: "age", Operator: OpLt, Value: "18"}}},
+		{"less or equal", "age=le=18", []Filter{{Field: "age", Operator: OpLte, Value: "18"}}},
+		{"greater symbol", "age>18", []Filter{{Field: "age", Operator: OpGt, Value: "18"}}},
+		{"greater or equal symbol", "age>=18", []Filter{{Field: "age", Operator: OpGte, Value: "18"}}},
+		{"less symbol", "age<18", []Filter{{Field: "age", Operator: OpLt, Value: "18"}}},
+		{"less or equal symbol", "age<=18", []Filter{{Field: "age", Operator: OpLte, Value: "18"}}},
+		{"like", "name=like=ann", []Filter{{Field: "name", Operator: OpLike, Value: "ann"}}},
+		{"in", "role=in=(admin,owner)", []Filter{{Field: "role", Operator: OpIn, Value: []string{"admin", "owner"}}}},
+		{"spaces", " role =in= ( admin , owner ) ; name == ann lee ", []Filter{
+			{Field: "role", Operator: OpIn, Value: []string{"admin", "owner"}},
+			{Field: "name", Operator: OpEq, Value: "ann lee"},
+		}},
+		{"single quotes", "name=='a;b,(c)'", []Filter{{Field: "name", Operator: OpEq, Value: "a;b,(c)"}}},
+		{"double quotes", `name=="it's"`, []Filter{{Field: "name", Operator: OpEq, Value: "it's"}}},
+		{"escaped quotes", `name=='it\'s \\ "x"'`, []Filter{{Field: "name", Operator: OpEq, Value: `it's \ "x"`}}},
+		{"quoted list", `role=in=('a,b',"c)")`, []Filter{{Field: "role", Operator: OpIn, Value: []string{"a,b", "c)"}}}},
+		{"and", "status==active;age=ge=18", []Filter{
+			{Field: "status", Operator: OpEq, Value: "active"},
+			{Field: "age", Operator: OpGte, Value: "18"},
+		}},
+		{"or", "status==active,age=ge=18", []Filter{{Or: [][]Filter{
+			{{Field: "status", Operator: OpEq, Value: "active"}},
+			{{Field: "age", Operator: OpGte, Value: "18"}},
+		}}}},
+		{"nested", "status==active;(role=in=(admin,owner),age=ge=18)", []Filter{
+			{Field: "status", Operator: OpEq, Value: "active"},
+			{Or: [][]Filter{
+				{{Field: "role", Operator: OpIn, Value: []string{"admin", "owner"}}},
+				{{Field: "age", Operator: OpGte, Value: "18"}},
+			}},
+		}},
+		{"flattened group", "(status==active;age=ge=18);name==ann", []Filter{
+			{Field: "status", Operator: OpEq, Value: "active"},
+			{Field: "age", Operator: OpGte, Value: "18"},
+			{Field: "name", Operator: OpEq, Value: "ann"},
+		}},
+		{"deeply nested", "(a==1,(b==2;(c==3,d==4)))", []Filter{{Or: [][]Filter{
+			{{Field: "a", Operator: OpEq, Value: "1"}},
+			{
+				{Field: "b", Operator: OpEq, Value: "2"},
+				{Or: [][]Filter{
+					{{Field: "c", Operator: OpEq, Value: "3"}},
+					{{Field: "d", Operator: OpEq, Value: "4"}},
+				}},
+			},
+		}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, err := ParseRsql(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(filters, test.want) {
+				t.Fatalf("expected %+v, got %+v", test.want, filters)
+			}
+		})
+	}
+}
+
+func TestParseRsqlInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		message    string
+	}{
+		{"empty", "", "missing field"},
+		{"missing field", "==active", "missing field"},
+		{"unknown operator", "status=is=active", "unknown operator after 'status'"},
+		{"missing operator", "status", "unknown operator after 'status'"},
+		{"missing value", "status==", "missing value"},
+		{"missing value before and", "status==;age=ge=18", "missing value"},
+		{"unterminated string", "name=='ann", "unterminated string"},
+		{"in without list", "role=in=admin", "=in= expects a list"},
+		{"unclosed list", "role=in=(admin,owner", "missing ')'"},
+		{"empty list value", "role=in=(admin,)", "missing value"},
+		{"unclosed group", "(status==active;age=ge=18", "missing ')'"},
+		{"unopened group", "status==active)", "unexpected ')'"},
+		{"trailing and", "status==active;", "missing field"},
+		{"trailing or", "status==active,", "missing field"},
+		{"text after quotes", "name=='ann' lee", "unexpected 'l'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, err := ParseRsql(test.expression)
+			expectBadRequest(t, err)
+
+			if filters != nil || !strings.Contains(err.Error(), test.message) {
+				t.Fatalf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestParseFilterQuery(t *testing.T) {
+	filters, err := ParseFilterQuery(map[string]string{
+		"filter[status]":         "active",
+		"filter[createdAt][GTE]": "2024-01-01",
+		"filter[role][in]":       "admin, owner",
+		"filter":                 "name=like=ann",
+		"sort":                   "-name",
+		"page":                   "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Filter{
+		{Field: "name", Operator: OpLike, Value: "ann"},
+		{Field: "createdAt", Operator: OpGte, Value: "2024-01-01"},
+		{Field: "role", Operator: OpIn, Value: []string{"admin", "owner"}},
+		{Field: "status", Operator: OpEq, Value: "active"},
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Fatalf("expected %+v, got %+v", want, filters)
+	}
+
+	for _, key := range []string{"filter[]", "filter[a", "filter[a][]", "filter[][eq]", "filter[a][eq][b]", "filter[a]x"} {
+		t.Run(key, func(t *testing.T) {
+			_, err := ParseFilterQuery(map[string]string{key: "1"})
+			expectBadRequest(t, err)
+		})
+	}
+
+	_, err = ParseFilterQuery(map[string]string{"filter": "status=="})
+	expectBadRequest(t, err)
+}
+
+func TestParsedFiltersAreWhitelisted(t *testing.T) {
+	whitelist := Whitelist{
+		"name":   {Column: "users.name", Sortable: true, Operators: []FilterOperator{OpEq, OpLike}},
+		"age":    {Column: "users.age", Operators: []FilterOperator{OpGte, OpLte}},
+		"secret": {Column: "users.secret", Sortable: true},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		message    string
+	}{
+		{"unknown field", "password==x", "can not filter by 'password', allowed fields: age, name"},
+		{"field without operators", "secret==x", "can not filter by 'secret'"},
+		{"column name", "users.name==x", "can not filter by 'users.name'"},
+		{"operator not allowed", "age==18", "can not filter 'age' with 'eq', allowed operators: gte, lte"},
+		{"unknown field in a group", "name==ann;(age=ge=18,password==x)", "can not filter by 'password'"},
+		{"operator not allowed in a list", "name=in=(ann,bob)", "can not filter 'name' with 'in'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, err := ParseRsql(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = whitelist.Where(filters)
+			expectBadRequest(t, err)
+
+			if !strings.Contains(err.Error(), test.message) {
+				t.Fatalf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+
+	filters, err := ParseRsql("name=like=ann;(age=ge=18,age=le=65)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if where, err := whitelist.Where(filters); err != nil || len(where) != 2 {
+		t.Fatalf("expected 2 conditions, got %v (%v)", where, err)
+	}
+}
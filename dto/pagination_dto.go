@@ -1,7 +1,11 @@
 package dto
 
 import (
+	"math"
+	"regexp"
 	"strings"
+
+	"gorm.io/gorm/clause"
 )
 
 type Pagination struct {
@@ -13,36 +17,117 @@ type Pagination struct {
 	TotalData  int64       `json:"totalData"`
 	TotalPages int         `json:"totalPages"`
 	Rows       interface{} `json:"-"`
+
+	// Filters are validated by Allow, see Clauses.
+	Filters []Filter `json:"-" query:"-"`
+
+	orderBy *clause.OrderBy
+	where   []clause.Expression
 }
 
+// Allow validates the sort and the filters of p against the whitelist of the
+// endpoint and returns a bad request XgoError listing the allowed fields when
+// they are invalid. Afterwards GetSort and Clauses use the whitelisted columns.
+func (p *Pagination) Allow(whitelist Whitelist) error {
+	orderBy, err := whitelist.OrderBy(ParseSort(p.SortBy, p.SortOrder))
+	if err != nil {
+		return err
+	}
+
+	where, err := whitelist.Where(p.Filters)
+	if err != nil {
+		return err
+	}
+
+	p.orderBy = &orderBy
+	p.where = where
+
+	return nil
+}
+
+// ParseFilters sets the Filters of p from the query string, see
+// ParseFilterQuery, to be validated by Allow:
+//
+//	pagination.ParseFilters(c.Queries())
+func (p *Pagination) ParseFilters(query map[string]string) error {
+	filters, err := ParseFilterQuery(query)
+	if err != nil {
+		return err
+	}
+
+	p.Filters = filters
+
+	return nil
+}
+
+// Clauses returns the conditions of the filters validated by Allow.
+func (p *Pagination) Clauses() []clause.Expression {
+	return p.where
+}
+
+// GetOffset returns the offset of the page, see GetPage and GetLimit.
 func (p *Pagination) GetOffset() int {
 	return (p.GetPage() - 1) * p.GetLimit()
 }
 
+// MaxLimit is the largest page size of a Pagination or a CursorPagination,
+// larger limits are clamped to it.
+var MaxLimit = 100
+
+// maxPage keeps the offset of the last page within an int.
+const maxPage = math.MaxInt32
+
+// GetLimit returns the page size, 10 by default or when negative, and at
+// most MaxLimit.
 func (p *Pagination) GetLimit() int {
-	if p.Limit == 0 {
+	if p.Limit <= 0 {
 		p.Limit = 10
 	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
 	return p.Limit
 }
 
+// GetPage returns the page number, 1 by default or when negative, and at
+// most math.MaxInt32.
 func (p *Pagination) GetPage() int {
-	if p.Page == 0 {
+	if p.Page <= 0 {
 		p.Page = 1
 	}
+	if p.Page > maxPage {
+		p.Page = maxPage
+	}
 	return p.Page
 }
 
-func (p *Pagination) GetSort() string {
-	sortDir := strings.ToUpper(p.SortOrder)
+var sortIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
 
-	if p.SortBy == "" && (sortDir == "") {
-		return ""
+// GetSort returns the ORDER BY of SortBy, e.g. "name ASC, created_at DESC"
+// for "name,-created_at", see ParseSort. Once Allow is called, the whitelisted
+// columns are returned, otherwise fields which are not plain column names are
+// ignored.
+func (p *Pagination) GetSort() string {
+	var columns []string
+	if p.orderBy != nil {
+		for _, column := range p.orderBy.Columns {
+			columns = append(columns, sortColumn(column.Column.Name, column.Desc))
+		}
+	} else {
+		for _, field := range ParseSort(p.SortBy, p.SortOrder) {
+			if sortIdentifier.MatchString(field.Field) {
+				columns = append(columns, sortColumn(field.Field, field.Desc))
+			}
+		}
 	}
 
-	if sortDir == "" {
-		p.SortOrder = "ASC"
+	return strings.Join(columns, ", ")
+}
+
+func sortColumn(column string, desc bool) string {
+	if desc {
+		return column + " DESC"
 	}
 
-	return p.SortBy + " " + sortDir
+	return column + " ASC"
 }
@@ -0,0 +1,27 @@
+package dto
+
+import "testing"
+
+func TestPaginationBounds(t *testing.T) {
+	tests := []struct {
+		name                string
+		pagination          Pagination
+		limit, page, offset int
+	}{
+		{"defaults", Pagination{}, 10, 1, 0},
+		{"second page", Pagination{Page: 2, Limit: 20}, 20, 2, 20},
+		{"negative limit", Pagination{Page: 2, Limit: -1}, 10, 2, 10},
+		{"negative page", Pagination{Page: -3, Limit: 5}, 5, 1, 0},
+		{"limit above the max", Pagination{Limit: 1 << 40}, MaxLimit, 1, 0},
+		{"page above the max", Pagination{Page: 1 << 40, Limit: 100}, 100, maxPage, (maxPage - 1) * 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.pagination
+			if limit, page, offset := p.GetLimit(), p.GetPage(), p.GetOffset(); limit != test.limit || page != test.page || offset != test.offset {
+				t.Fatalf("expected the limit %d, page %d and offset %d, got %d, %d and %d", test.limit, test.page, test.offset, limit, page, offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"strings"
+)
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a comma separated list of fields, each optionally prefixed
+// with `-` for a descending or `+` for an ascending order, e.g.
+// "name,-createdAt". sortOrder, ASC or DESC, applies to the unprefixed fields.
+func ParseSort(sortBy string, sortOrder string) []SortField {
+	defaultDesc := strings.EqualFold(strings.TrimSpace(sortOrder), "DESC")
+
+	var fields []SortField
+	for _, field := range strings.Split(sortBy, ",") {
+		field = strings.TrimSpace(field)
+		desc := defaultDesc
+
+		switch {
+		case strings.HasPrefix(field, "-"):
+			field, desc = strings.TrimSpace(field[1:]), true
+		case strings.HasPrefix(field, "+"):
+			field, desc = strings.TrimSpace(field[1:]), false
+		}
+
+		if field != "" {
+			fields = append(fields, SortField{Field: field, Desc: desc})
+		}
+	}
+
+	return fields
+}
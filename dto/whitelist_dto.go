@@ -0,0 +1,239 @@
+package dto
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	xgoErrors "github.com/anoaland/xgo/errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+)
+
+type FilterOperator string
+
+const (
+	OpEq   FilterOperator = "eq"
+	OpNe   FilterOperator = "ne"
+	OpGt   FilterOperator = "gt"
+	OpGte  FilterOperator = "gte"
+	OpLt   FilterOperator = "lt"
+	OpLte  FilterOperator = "lte"
+	OpIn   FilterOperator = "in"
+	OpLike FilterOperator = "like"
+)
+
+// Filter is a condition requested on a public field name.
+type Filter struct {
+	Field    string
+	Operator FilterOperator
+	// Value is a slice for OpIn.
+	Value interface{}
+
+	// Or, when set, makes the filter a disjunction of its conjunctions,
+	// ignoring the other fields.
+	Or [][]Filter
+}
+
+// WhitelistField maps a public field name to its column.
+type WhitelistField struct {
+	// Column is the column, qualified when joins make it ambiguous,
+	// e.g. "users.created_at". It is written as is in the statement.
+	Column string
+
+	// Sortable allows sorting by the field.
+	Sortable bool
+
+	// Operators allows filtering the field with these operators.
+	// Optional. Default: no filtering.
+	Operators []FilterOperator
+
+	// Coerce converts the string values parsed from a query, e.g. to a
+	// time.Time. See repository.BindWhitelist.
+	// Optional. Default: values are kept as strings.
+	Coerce func(value string) (interface{}, error)
+}
+
+// Whitelist declares the public field names an endpoint can be sorted and
+// filtered by:
+//
+//	var userFields = dto.Whitelist{
+//		"name":      {Column: "users.name", Sortable: true, Operators: []dto.FilterOperator{dto.OpEq, dto.OpLike}},
+//		"createdAt": {Column: "users.created_at", Sortable: true, Operators: []dto.FilterOperator{dto.OpGte, dto.OpLte}},
+//	}
+type Whitelist map[string]WhitelistField
+
+// OrderBy returns the order of sorts, or a bad request XgoError listing the
+// sortable fields when a field is not sortable.
+func (w Whitelist) OrderBy(sorts []SortField) (clause.OrderBy, error) {
+	var orderBy clause.OrderBy
+	for _, sort := range sorts {
+		field, ok := w[sort.Field]
+		if !ok || !field.Sortable {
+			return orderBy, badRequest("E_PAGINATION_SORT",
+				fmt.Errorf("can not sort by '%s', allowed fields: %s", sort.Field, w.names(func(f WhitelistField) bool { return f.Sortable })))
+		}
+
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{
+			Column: clause.Column{Name: field.Column, Raw: true},
+			Desc:   sort.Desc,
+		})
+	}
+
+	return orderBy, nil
+}
+
+// Where returns the conditions of filters, or a bad request XgoError listing
+// the allowed fields or operators when a filter is not allowed.
+func (w Whitelist) Where(filters []Filter) ([]clause.Expression, error) {
+	var expressions []clause.Expression
+	for _, filter := range filters {
+		if len(filter.Or) > 0 {
+			var or []clause.Expression
+			for _, group := range filter.Or {
+				and, err := w.Where(group)
+				if err != nil {
+					return nil, err
+				}
+				or = append(or, clause.And(and...))
+			}
+			expressions = append(expressions, clause.Or(or...))
+			continue
+		}
+
+		field, ok := w[filter.Field]
+		if !ok || len(field.Operators) == 0 {
+			return nil, badRequest("E_PAGINATION_FILTER",
+				fmt.Errorf("can not filter by '%s', allowed fields: %s", filter.Field, w.names(func(f WhitelistField) bool { return len(f.Operators) > 0 })))
+		}
+
+		if !slices.Contains(field.Operators, filter.Operator) {
+			operators := make([]string, len(field.Operators))
+			for i, operator := range field.Operators {
+				operators[i] = string(operator)
+			}
+			return nil, badRequest("E_PAGINATION_FILTER",
+				fmt.Errorf("can not filter '%s' with '%s', allowed operators: %s", filter.Field, filter.Operator, strings.Join(operators, ", ")))
+		}
+
+		value, err := field.coerce(filter.Value)
+		if err != nil {
+			return nil, badRequest("E_PAGINATION_FILTER", fmt.Errorf("invalid value for '%s': %w", filter.Field, err))
+		}
+		filter.Value = value
+
+		expression, err := filterExpression(clause.Column{Name: field.Column, Raw: true}, filter)
+		if err != nil {
+			return nil, badRequest("E_PAGINATION_FILTER", err)
+		}
+		expressions = append(expressions, expression)
+	}
+
+	return expressions, nil
+}
+
+// Condition returns the conditions of filters joined with AND, e.g. for the
+// conds of Repository.FindAll. See Where.
+func (w Whitelist) Condition(filters []Filter) (clause.Expression, error) {
+	expressions, err := w.Where(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return clause.And(expressions...), nil
+}
+
+func (f WhitelistField) coerce(value interface{}) (interface{}, error) {
+	if f.Coerce == nil {
+		if values, ok := value.([]string); ok {
+			converted := make([]interface{}, len(values))
+			for i, v := range values {
+				converted[i] = v
+			}
+			return converted, nil
+		}
+
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return f.Coerce(v)
+	case []string:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			coerced, err := f.Coerce(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = coerced
+		}
+		return converted, nil
+	}
+
+	return value, nil
+}
+
+func (w Whitelist) names(allowed func(WhitelistField) bool) string {
+	var names []string
+	for name, field := range w {
+		if allowed(field) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+func filterExpression(column clause.Column, filter Filter) (clause.Expression, error) {
+	switch filter.Operator {
+	case OpEq:
+		return clause.Eq{Column: column, Value: filter.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: column, Value: filter.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: column, Value: filter.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: column, Value: filter.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: column, Value: filter.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: column, Value: filter.Value}, nil
+	case OpIn:
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			values = []interface{}{filter.Value}
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case OpLike:
+		value, ok := filter.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("'%s' like expects a string", filter.Field)
+		}
+		return clause.Expr{
+			SQL:  "LOWER(?) LIKE ? ESCAPE '!'",
+			Vars: []interface{}{column, "%" + EscapeLike(strings.ToLower(value)) + "%"},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator '%s'", filter.Operator)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, `[` being one for SQL
+// Server. The escape character is not a backslash, which MySQL would read as
+// escaping the closing quote of `ESCAPE '\'`.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "[", "![")
+
+// EscapeLike escapes the wildcards of value, to be matched literally by a
+// LIKE pattern with `ESCAPE '!'`.
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// badRequest returns a bad request XgoError without importing the xgo
+// package, so that dto does not depend on the server.
+func badRequest(part string, err error) *xgoErrors.XgoError {
+	return xgoErrors.NewHttpError(part, err, fiber.StatusBadRequest, 2)
+}
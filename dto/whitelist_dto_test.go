@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+
+	xgoErrors "github.com/anoaland/xgo/errors"
+	"gorm.io/gorm/clause"
+)
+
+func TestLikeEscapesWildcards(t *testing.T) {
+	whitelist := Whitelist{"name": {Column: "name", Operators: []FilterOperator{OpLike}}}
+
+	where, err := whitelist.Where([]Filter{{Field: "name", Operator: OpLike, Value: `50%_OFF!\[x]`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := where[0].(clause.Expr)
+	if want := `%50!%!_off!!\![x]%`; expr.Vars[1] != want {
+		t.Fatalf("expected the pattern %q, got %q", want, expr.Vars[1])
+	}
+}
+
+func TestWhitelistErrorsAreBadRequests(t *testing.T) {
+	_, err := Whitelist{}.Where([]Filter{{Field: "name", Operator: OpEq, Value: "a"}})
+
+	var xgoErr *xgoErrors.XgoError
+	if !errors.As(err, &xgoErr) || xgoErr.HttpErrorCode != 400 || xgoErr.Part != "E_PAGINATION_FILTER" {
+		t.Fatalf("expected a bad request XgoError, got %v", err)
+	}
+}
@@ -19,6 +19,8 @@ type XgoError struct {
 	HttpErrorCode int
 	Stack         string
 	Callers       []string
+	// Cause is the wrapped error, see Unwrap.
+	Cause error
 }
 
 type XgoHttpError struct {
@@ -70,6 +72,7 @@ func NewHttpError(part string, err error, httpErrorCode int, callerSkip int) *Xg
 		HttpErrorCode: httpErrorCode,
 		IsFatal:       httpErrorCode == fiber.StatusInternalServerError,
 		Stack:         strings.Join(stack, "\n"),
+		Cause:         err,
 	}
 }
 
@@ -82,6 +85,12 @@ func (e *XgoError) Error() string {
 	return fmt.Sprintf("%s %s\r\n\t%s:%d", identity, e.Message, e.File, e.Line)
 }
 
+// Unwrap returns the wrapped error, so that errors.Is and errors.As reach
+// the original error, e.g. a driver error.
+func (e *XgoError) Unwrap() error {
+	return e.Cause
+}
+
 func (err *XgoError) Print() {
 	logger := pterm.DefaultLogger.WithLevel(pterm.LogLevelTrace)
 	args := []any{
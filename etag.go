@@ -0,0 +1,35 @@
+package xgo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetVersionETag sets the ETag of the response to the version of the
+// returned record, to be sent back in the If-Match header of an update.
+func SetVersionETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`"%d"`, version))
+}
+
+// IfMatchVersion returns the version of the If-Match header of the request,
+// an ETag set by SetVersionETag, to update the record with optimistic locking,
+// see repository.Repository.Update. A missing header returns a 428 and a
+// malformed one a 412 XgoError.
+func IfMatchVersion(c *fiber.Ctx) (int64, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return 0, NewHttpCustomError("E_IF_MATCH", fiber.StatusPreconditionRequired, errors.New("the If-Match header is required"))
+	}
+
+	etag := strings.TrimPrefix(header, "W/")
+	version, err := strconv.ParseInt(strings.Trim(etag, `"`), 10, 64)
+	if err != nil || version <= 0 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		return 0, NewHttpCustomError("E_IF_MATCH", fiber.StatusPreconditionFailed, fmt.Errorf("invalid If-Match header '%s'", header))
+	}
+
+	return version, nil
+}
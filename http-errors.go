@@ -37,12 +37,20 @@ func NewHttpCustomError(part string, httpStatusCode int, err error) *xgoErrors.X
 	return xgoErrors.NewHttpError(part, err, httpStatusCode, 1)
 }
 
+// HttpStatusError is implemented by errors knowing their HTTP status code,
+// e.g. repository.NotFoundError.
+type HttpStatusError interface {
+	error
+	HttpStatusCode() int
+}
+
 // AsXgoError converts a given error into an XgoError. It attempts to match the error
 // to known error types and returns a corresponding XgoError. If the error is of type
 // *fiber.Error, it creates a new XgoError with the "FIBER" category. If the error is
 // of type *gocloak.APIError, it parses the error message and creates a new XgoError
-// with the "AUTH_ERROR" category and appropriate HTTP status code. If the error does
-// not match any known types, it returns a general XgoError.
+// with the "AUTH_ERROR" category and appropriate HTTP status code. If the error is an
+// HttpStatusError, it creates a new XgoError with the "HTTP_ERROR" category and its
+// status code. If the error does not match any known types, it returns a general XgoError.
 //
 // Parameters:
 //   - err: The error to be converted.
@@ -83,6 +91,18 @@ func AsXgoError(err error) *xgoErrors.XgoError {
 		}
 	}
 
+	var statusErr HttpStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.HttpStatusCode()
+
+		return &xgoErrors.XgoError{
+			Message:       err.Error(),
+			IsFatal:       code >= fiber.StatusInternalServerError,
+			HttpErrorCode: code,
+			Part:          "HTTP_ERROR",
+		}
+	}
+
 	return &xgoErrors.XgoError{
 		Message:       err.Error(),
 		IsFatal:       true,
@@ -6,9 +6,15 @@ const (
 	RequestIDKey     = "xgo_use_logger_requestID"
 	StartTimeKey     = "xgo_use_logger_startTime"
 	StackErrorKey    = "xgo_use_logger_stackError"
+
+	RequestStatsKey         = "xgo_use_logger_requestStats"
+	SlowRequestThresholdKey = "xgo_use_logger_slowRequestThreshold"
 )
 
 // Define context key type to avoid collisions
 type ContextKey string
 
-const FiberContextKey ContextKey = "fiber"
+const (
+	RequestIDContextKey    ContextKey = "xgo_request_id"
+	RequestStatsContextKey ContextKey = "xgo_request_stats"
+)
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// LoggerFromContext returns the logger stored in ctx by zerolog's WithContext,
+// or nil when there is none.
+func LoggerFromContext(ctx context.Context) *zerolog.Logger {
+	if ctx == nil {
+		return nil
+	}
+
+	logger := zerolog.Ctx(ctx)
+	if logger == nil || logger.GetLevel() == zerolog.Disabled {
+		return nil
+	}
+
+	return logger
+}
+
+// RequestIDFromContext returns the request id stored in ctx, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	requestID, _ := ctx.Value(RequestIDContextKey).(string)
+	return requestID
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RequestStats aggregates the database activity of a single request.
+// It is filled by the GORM logger and read by the access log.
+type RequestStats struct {
+	mu        sync.Mutex
+	dbTime    time.Duration
+	dbQueries int
+	queries   map[string]int
+}
+
+// WithRequestStats returns a copy of ctx carrying a new RequestStats.
+func WithRequestStats(ctx context.Context) (context.Context, *RequestStats) {
+	stats := &RequestStats{queries: map[string]int{}}
+	return context.WithValue(ctx, RequestStatsContextKey, stats), stats
+}
+
+// RequestStatsFromContext returns the RequestStats stored in ctx, or nil.
+func RequestStatsFromContext(ctx context.Context) *RequestStats {
+	if ctx == nil {
+		return nil
+	}
+
+	stats, _ := ctx.Value(RequestStatsContextKey).(*RequestStats)
+	return stats
+}
+
+// AddQuery records a query and returns how many times the same normalized
+// statement ran within the request so far.
+func (s *RequestStats) AddQuery(normalizedSql string, latency time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dbTime += latency
+	s.dbQueries++
+	s.queries[normalizedSql]++
+
+	return s.queries[normalizedSql]
+}
+
+// Db returns the total time spent in queries and the number of queries.
+func (s *RequestStats) Db() (time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dbTime, s.dbQueries
+}
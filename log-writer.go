@@ -0,0 +1,57 @@
+package xgo
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anoaland/xgo/utils"
+)
+
+const (
+	// LogFormatJson writes compact single-line JSON, suited for production.
+	LogFormatJson = "json"
+	// LogFormatPretty writes indented and colorized JSON, suited for development.
+	LogFormatPretty = "pretty"
+	// LogFormatConsole writes a single `key=value` line per log.
+	LogFormatConsole = "console"
+)
+
+// LogWriter returns the built-in writer for the given format, writing to stdout.
+// Unknown formats fall back to LogFormatJson.
+func LogWriter(format string) io.Writer {
+	switch strings.ToLower(format) {
+	case LogFormatPretty:
+		return utils.JsonWriter{}
+	case LogFormatConsole:
+		return utils.KeyValueWriter{Out: os.Stdout}
+	default:
+		return os.Stdout
+	}
+}
+
+// LogFormatFromEnv resolves the log format from the environment.
+// `XGO_LOG_FORMAT` wins when set, otherwise `APP_ENV` (or `GO_ENV`) set to
+// production or staging selects LogFormatJson and anything else LogFormatPretty.
+func LogFormatFromEnv() string {
+	if format := os.Getenv("XGO_LOG_FORMAT"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = os.Getenv("GO_ENV")
+	}
+
+	switch strings.ToLower(env) {
+	case "production", "prod", "staging":
+		return LogFormatJson
+	}
+
+	return LogFormatPretty
+}
+
+// DefaultLogWriter returns the writer selected by LogFormatFromEnv.
+func DefaultLogWriter() io.Writer {
+	return LogWriter(LogFormatFromEnv())
+}
@@ -0,0 +1,233 @@
+package xgo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ContextLogger is a small structured logging API on top of the per-request
+// zerolog logger. Every level takes a message followed by key-value pairs:
+//
+//	xgo.Log(ctx).Info("user created", "user_id", user.ID, "admin", true)
+//	xgo.Log(ctx).Err(err).Error("failed to create user", "email", email)
+//
+// Values are written with their zerolog type (string, int, bool, time.Duration,
+// time.Time, error, ...), a slog.Attr can be passed in place of a pair.
+// Every entry includes the caller file and line.
+type ContextLogger struct {
+	logger *zerolog.Logger
+	err    error
+}
+
+// Log returns a ContextLogger for the logger carried by ctx, see LoggerFrom.
+func Log(ctx context.Context) *ContextLogger {
+	return &ContextLogger{logger: LoggerFrom(ctx)}
+}
+
+// Logger returns the underlying zerolog logger.
+func (l *ContextLogger) Logger() *zerolog.Logger {
+	return l.logger
+}
+
+// With returns a ContextLogger adding the given key-value pairs to every entry.
+func (l *ContextLogger) With(args ...any) *ContextLogger {
+	logger := appendContextFields(l.logger.With(), args).Logger()
+	return &ContextLogger{logger: &logger, err: l.err}
+}
+
+// Err returns a ContextLogger attaching err to the next entries.
+func (l *ContextLogger) Err(err error) *ContextLogger {
+	return &ContextLogger{logger: l.logger, err: err}
+}
+
+func (l *ContextLogger) Trace(msg string, args ...any) {
+	l.log(zerolog.TraceLevel, msg, args)
+}
+
+func (l *ContextLogger) Debug(msg string, args ...any) {
+	l.log(zerolog.DebugLevel, msg, args)
+}
+
+func (l *ContextLogger) Info(msg string, args ...any) {
+	l.log(zerolog.InfoLevel, msg, args)
+}
+
+func (l *ContextLogger) Warn(msg string, args ...any) {
+	l.log(zerolog.WarnLevel, msg, args)
+}
+
+func (l *ContextLogger) Error(msg string, args ...any) {
+	l.log(zerolog.ErrorLevel, msg, args)
+}
+
+// Fatal logs the entry then calls os.Exit(1).
+func (l *ContextLogger) Fatal(msg string, args ...any) {
+	l.log(zerolog.FatalLevel, msg, args)
+}
+
+// Panic logs the entry then panics with msg.
+func (l *ContextLogger) Panic(msg string, args ...any) {
+	l.log(zerolog.PanicLevel, msg, args)
+}
+
+// Level logs at the given level.
+func (l *ContextLogger) Level(level zerolog.Level, msg string, args ...any) {
+	l.log(level, msg, args)
+}
+
+func (l *ContextLogger) log(level zerolog.Level, msg string, args []any) {
+	// skip log and the exported level method
+	logEvent(l.logger, level, l.err, 2, msg, args)
+}
+
+// logEvent writes one entry at level. callerSkip is the number of frames
+// between the user's call and logEvent.
+func logEvent(logger *zerolog.Logger, level zerolog.Level, err error, callerSkip int, msg string, args []any) {
+	event := newLevelEvent(logger, level)
+	if event == nil {
+		return
+	}
+
+	if err != nil {
+		event = event.Err(err)
+	}
+
+	appendEventFields(event, args).Caller(callerSkip + 1).Msg(msg)
+}
+
+func newLevelEvent(logger *zerolog.Logger, level zerolog.Level) *zerolog.Event {
+	switch level {
+	case zerolog.TraceLevel:
+		return logger.Trace()
+	case zerolog.DebugLevel:
+		return logger.Debug()
+	case zerolog.InfoLevel:
+		return logger.Info()
+	case zerolog.WarnLevel:
+		return logger.Warn()
+	case zerolog.ErrorLevel:
+		return logger.Error()
+	case zerolog.FatalLevel:
+		return logger.Fatal()
+	case zerolog.PanicLevel:
+		return logger.Panic()
+	case zerolog.NoLevel:
+		return logger.Log()
+	}
+
+	return logger.WithLevel(level)
+}
+
+// badKey is used for a value without a key, following log/slog.
+const badKey = "!BADKEY"
+
+// fieldPairs walks args as key-value pairs, accepting slog.Attr in place of a pair.
+func fieldPairs(args []any, fn func(key string, value any)) {
+	for len(args) > 0 {
+		switch key := args[0].(type) {
+		case slog.Attr:
+			fn(key.Key, key.Value.Resolve().Any())
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				fn(badKey, key)
+				return
+			}
+			fn(key, args[1])
+			args = args[2:]
+		default:
+			fn(badKey, key)
+			args = args[1:]
+		}
+	}
+}
+
+func appendEventFields(event *zerolog.Event, args []any) *zerolog.Event {
+	fieldPairs(args, func(key string, value any) {
+		event = appendEventField(event, key, value)
+	})
+
+	return event
+}
+
+func appendEventField(event *zerolog.Event, key string, value any) *zerolog.Event {
+	switch v := value.(type) {
+	case string:
+		return event.Str(key, v)
+	case []string:
+		return event.Strs(key, v)
+	case int:
+		return event.Int(key, v)
+	case int8:
+		return event.Int8(key, v)
+	case int16:
+		return event.Int16(key, v)
+	case int32:
+		return event.Int32(key, v)
+	case int64:
+		return event.Int64(key, v)
+	case uint:
+		return event.Uint(key, v)
+	case uint8:
+		return event.Uint8(key, v)
+	case uint16:
+		return event.Uint16(key, v)
+	case uint32:
+		return event.Uint32(key, v)
+	case uint64:
+		return event.Uint64(key, v)
+	case float32:
+		return event.Float32(key, v)
+	case float64:
+		return event.Float64(key, v)
+	case bool:
+		return event.Bool(key, v)
+	case time.Duration:
+		return event.Str(key, v.String())
+	case time.Time:
+		return event.Time(key, v)
+	case error:
+		return event.AnErr(key, v)
+	case []byte:
+		return event.Bytes(key, v)
+	case fmt.Stringer:
+		return event.Stringer(key, v)
+	case nil:
+		return event.Interface(key, nil)
+	}
+
+	return event.Interface(key, value)
+}
+
+func appendContextFields(ctx zerolog.Context, args []any) zerolog.Context {
+	fieldPairs(args, func(key string, value any) {
+		switch v := value.(type) {
+		case string:
+			ctx = ctx.Str(key, v)
+		case int:
+			ctx = ctx.Int(key, v)
+		case int64:
+			ctx = ctx.Int64(key, v)
+		case float64:
+			ctx = ctx.Float64(key, v)
+		case bool:
+			ctx = ctx.Bool(key, v)
+		case time.Duration:
+			ctx = ctx.Str(key, v.String())
+		case time.Time:
+			ctx = ctx.Time(key, v)
+		case error:
+			ctx = ctx.AnErr(key, v)
+		case fmt.Stringer:
+			ctx = ctx.Stringer(key, v)
+		default:
+			ctx = ctx.Interface(key, v)
+		}
+	})
+
+	return ctx
+}
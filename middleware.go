@@ -1,14 +1,15 @@
 package xgo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/anoaland/xgo/internal"
-	"github.com/anoaland/xgo/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/google/uuid"
@@ -18,6 +19,14 @@ import (
 type UseLoggerConfig struct {
 	Writer io.Writer
 	Logger *zerolog.Logger
+	// Format selects one of the built-in writers (LogFormatJson, LogFormatPretty
+	// or LogFormatConsole) when neither Writer nor Logger is set.
+	// Optional. Default: resolved from the environment, see DefaultLogWriter.
+	Format string
+	// SlowRequestThreshold logs requests taking longer than this as warnings.
+	// It can be overridden per route with SlowThreshold or XRouter.WithSlowThreshold.
+	// Optional. Default: 0, disabled.
+	SlowRequestThreshold time.Duration
 }
 
 // LoggerFactory creates a new logger instance for each request
@@ -34,6 +43,8 @@ func NewLoggerFactory(config ...UseLoggerConfig) *LoggerFactory {
 			factory.baseLogger = config[0].Logger
 		} else if config[0].Writer != nil {
 			factory.baseWriter = config[0].Writer
+		} else if config[0].Format != "" {
+			factory.baseWriter = LogWriter(config[0].Format)
 		} else {
 			factory.baseWriter = DefaultLogWriter()
 		}
@@ -80,6 +91,11 @@ func (server *WebServer) UseLogger(config ...UseLoggerConfig) {
 	// Create logger factory to avoid global logger issues
 	loggerFactory := NewLoggerFactory(config...)
 
+	var defaultSlowThreshold time.Duration
+	if len(config) > 0 {
+		defaultSlowThreshold = config[0].SlowRequestThreshold
+	}
+
 	startTimeHandler := func(ctx *fiber.Ctx) error {
 		requestID := ctx.Get("x-request-id")
 		if requestID == "" {
@@ -92,6 +108,14 @@ func (server *WebServer) UseLogger(config ...UseLoggerConfig) {
 		// Create a fresh logger instance for this request - no shared state
 		requestLogger := loggerFactory.CreateRequestLogger(requestID)
 		ctx.Locals(internal.RequestLoggerKey, requestLogger)
+
+		// Propagate the logger, request id and database stats through the user
+		// context so that layers without access to the fiber.Ctx can use them
+		userCtx := context.WithValue(ctx.UserContext(), internal.RequestIDContextKey, requestID)
+		userCtx, stats := internal.WithRequestStats(userCtx)
+		ctx.Locals(internal.RequestStatsKey, stats)
+		ctx.SetUserContext(requestLogger.WithContext(userCtx))
+
 		return ctx.Next()
 	}
 
@@ -121,13 +145,26 @@ func (server *WebServer) UseLogger(config ...UseLoggerConfig) {
 		// Get the per-request logger
 		requestLogger := ctx.Locals(internal.RequestLoggerKey).(*zerolog.Logger)
 
+		slowThreshold := defaultSlowThreshold
+		if threshold, ok := ctx.Locals(internal.SlowRequestThresholdKey).(time.Duration); ok {
+			slowThreshold = threshold
+		}
+		isSlow := slowThreshold > 0 && latency > slowThreshold
+
 		if err == nil {
-			requestLogger.Info().Ctx(ctx.UserContext()).
+			msg := "success"
+			evt := requestLogger.Info()
+			if isSlow {
+				msg = "slow request"
+				evt = requestLogger.Warn().Str("slow_threshold", slowThreshold.String())
+			}
+
+			evt = evt.Ctx(ctx.UserContext()).
 				Str("path", ctx.Path()).
 				Str("method", ctx.Method()).
 				Str("ip", ctx.IP()).
-				Str("latency", latency.String()).
-				Msg("success")
+				Str("latency", latency.String())
+			withDbStats(evt, ctx).Msg(msg)
 			return nil
 		}
 
@@ -142,6 +179,11 @@ func (server *WebServer) UseLogger(config ...UseLoggerConfig) {
 			Str("message", xgoError.Message).
 			Str("part", xgoError.Part)
 
+		if isSlow {
+			evt.Str("slow_threshold", slowThreshold.String())
+		}
+		withDbStats(evt, ctx)
+
 		var stack []string
 		if stackError := ctx.Locals(internal.StackErrorKey); stackError != nil {
 			stack = stackError.([]string)
@@ -170,6 +212,33 @@ func (server *WebServer) UseLogger(config ...UseLoggerConfig) {
 	server.App.Use(panicRecoverHandler)
 }
 
+// withDbStats adds the time spent in queries and their count, collected by the
+// GORM logger from the request context, to the access log entry.
+func withDbStats(evt *zerolog.Event, ctx *fiber.Ctx) *zerolog.Event {
+	stats, ok := ctx.Locals(internal.RequestStatsKey).(*internal.RequestStats)
+	if !ok {
+		return evt
+	}
+
+	dbTime, dbQueries := stats.Db()
+	if dbQueries == 0 {
+		return evt
+	}
+
+	return evt.Str("db_time", dbTime.String()).Int("db_queries", dbQueries)
+}
+
+// SlowThreshold returns a middleware overriding UseLoggerConfig.SlowRequestThreshold
+// for the routes it is applied to. Requests taking longer are logged as warnings.
+//
+//	app.Get("/reports", xgo.SlowThreshold(5*time.Second), handler)
+func SlowThreshold(threshold time.Duration) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.Locals(internal.SlowRequestThresholdKey, threshold)
+		return ctx.Next()
+	}
+}
+
 // GetRequestLogger retrieves the request-specific logger from the Fiber context.
 // This logger already includes the request_id in its context.
 // Returns nil if no logger is found (middleware not properly set up).
@@ -191,34 +260,69 @@ func GetRequestID(ctx *fiber.Ctx) string {
 	return requestID.(string)
 }
 
+// RequestContext returns the context.Context of the request, carrying the
+// per-request logger and request id. It is derived from ctx.UserContext(), so
+// cancellation and deadlines set by other middlewares are preserved, and it
+// does not hold on to the fiber.Ctx, which fiber recycles after the handler returns.
+func RequestContext(ctx *fiber.Ctx) context.Context {
+	userCtx := ctx.UserContext()
+
+	if internal.LoggerFromContext(userCtx) == nil {
+		if logger := GetRequestLogger(ctx); logger != nil {
+			userCtx = logger.WithContext(context.WithValue(userCtx, internal.RequestIDContextKey, GetRequestID(ctx)))
+		}
+	}
+
+	if internal.RequestStatsFromContext(userCtx) == nil {
+		if stats, ok := ctx.Locals(internal.RequestStatsKey).(*internal.RequestStats); ok {
+			userCtx = context.WithValue(userCtx, internal.RequestStatsContextKey, stats)
+		}
+	}
+
+	return userCtx
+}
+
+// LoggerFrom retrieves the request-specific logger from a context.Context, such
+// as the one returned by RequestContext. It can be used in any layer (services,
+// repositories, goroutines) without access to the fiber.Ctx.
+// Falls back to a default logger when ctx carries none, so it never returns nil.
+func LoggerFrom(ctx context.Context) *zerolog.Logger {
+	if logger := internal.LoggerFromContext(ctx); logger != nil {
+		return logger
+	}
+
+	return fallbackLogger()
+}
+
+// RequestIDFrom retrieves the request ID from a context.Context.
+// Returns empty string if no request ID is found.
+func RequestIDFrom(ctx context.Context) string {
+	return internal.RequestIDFromContext(ctx)
+}
+
+var (
+	fallbackLoggerOnce sync.Once
+	fallbackLoggerInst zerolog.Logger
+)
+
+func fallbackLogger() *zerolog.Logger {
+	fallbackLoggerOnce.Do(func() {
+		fallbackLoggerInst = zerolog.New(DefaultLogWriter()).With().Timestamp().Logger()
+	})
+
+	return &fallbackLoggerInst
+}
+
 // LogWithContext is a helper function that logs with the request context if available.
 // If no request logger is found, it falls back to a default logger.
 // This is useful for logging outside of HTTP handlers.
-func LogWithContext(ctx *fiber.Ctx, level zerolog.Level, msg string) {
+// Every zerolog level is supported and args are key-value pairs, see ContextLogger.
+func LogWithContext(ctx *fiber.Ctx, level zerolog.Level, msg string, args ...any) {
 	logger := GetRequestLogger(ctx)
 	if logger == nil {
 		// Fallback to default logger if no request logger available
-		defaultLogger := zerolog.New(DefaultLogWriter()).With().Timestamp().Logger()
-		logger = &defaultLogger
-	}
-
-	var event *zerolog.Event
-	switch level {
-	case zerolog.InfoLevel:
-		event = logger.Info()
-	case zerolog.WarnLevel:
-		event = logger.Warn()
-	case zerolog.ErrorLevel:
-		event = logger.Error()
-	case zerolog.DebugLevel:
-		event = logger.Debug()
-	default:
-		event = logger.Info()
+		logger = fallbackLogger()
 	}
 
-	event.Msg(msg)
-}
-
-func DefaultLogWriter() io.Writer {
-	return utils.JsonWriter{}
+	logEvent(logger, level, nil, 1, msg, args)
 }
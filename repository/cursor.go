@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	cursorSecret     []byte
+	cursorSecretOnce sync.Once
+	cursorSecretMu   sync.RWMutex
+)
+
+// SetCursorSecret sets the key signing the pagination cursors. It defaults to
+// the XGO_CURSOR_SECRET environment variable, or a random key, in which case
+// cursors are only valid within the process.
+func SetCursorSecret(secret []byte) {
+	cursorSecretOnce.Do(func() {})
+
+	cursorSecretMu.Lock()
+	defer cursorSecretMu.Unlock()
+
+	cursorSecret = secret
+}
+
+func getCursorSecret() []byte {
+	cursorSecretOnce.Do(func() {
+		if secret := os.Getenv("XGO_CURSOR_SECRET"); secret != "" {
+			cursorSecret = []byte(secret)
+			return
+		}
+
+		cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cursorSecret); err != nil {
+			panic(err)
+		}
+	})
+
+	cursorSecretMu.RLock()
+	defer cursorSecretMu.RUnlock()
+
+	return cursorSecret
+}
+
+// cursor is the position of a row in a keyset pagination.
+type cursor struct {
+	// Sort is the signature of the sort, a cursor is only valid for the same sort.
+	Sort string `json:"s"`
+	// Prev is set for a cursor to the rows before the position.
+	Prev   bool          `json:"p,omitempty"`
+	Values []cursorValue `json:"v"`
+}
+
+// cursorValue keeps the type of a sort key value through JSON.
+type cursorValue struct {
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// encodeCursor returns the signed, URL safe token of c.
+func encodeCursor(c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, getCursorSecret())
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// decodeCursor verifies the token and returns its cursor, nil for an empty token.
+func decodeCursor(token string, sort string) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	encodedPayload, encodedMac, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedMac)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, getCursorSecret())
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.Sort != sort {
+		return nil, fmt.Errorf("%w: created for another sort", ErrInvalidCursor)
+	}
+
+	return &c, nil
+}
+
+func newCursorValue(value interface{}) (cursorValue, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return cursorValue{}, err
+		}
+		value = v
+	}
+
+	if value == nil {
+		return cursorValue{Type: "null"}, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return cursorValue{Type: "null"}, nil
+		}
+		return newCursorValue(rv.Elem().Interface())
+	}
+
+	var kind string
+	switch v := value.(type) {
+	case time.Time:
+		kind, value = "time", v.Format(time.RFC3339Nano)
+	case []byte:
+		kind, value = "bytes", v
+	default:
+		switch rv.Kind() {
+		case reflect.String:
+			kind, value = "string", rv.String()
+		case reflect.Bool:
+			kind, value = "bool", rv.Bool()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			kind, value = "int", rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			kind, value = "uint", rv.Uint()
+		case reflect.Float32, reflect.Float64:
+			kind, value = "float", rv.Float()
+		default:
+			return cursorValue{}, fmt.Errorf("unsupported sort key type %T", value)
+		}
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return cursorValue{}, err
+	}
+
+	return cursorValue{Type: kind, Value: raw}, nil
+}
+
+func (v cursorValue) decode() (interface{}, error) {
+	var err error
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "time":
+		var s string
+		if err = json.Unmarshal(v.Value, &s); err == nil {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case "bytes":
+		var b []byte
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case "string":
+		var s string
+		err = json.Unmarshal(v.Value, &s)
+		return s, err
+	case "bool":
+		var b bool
+		err = json.Unmarshal(v.Value, &b)
+		return b, err
+	case "int":
+		var i int64
+		err = json.Unmarshal(v.Value, &i)
+		return i, err
+	case "uint":
+		var u uint64
+		err = json.Unmarshal(v.Value, &u)
+		return u, err
+	case "float":
+		var f float64
+		err = json.Unmarshal(v.Value, &f)
+		return f, err
+	default:
+		err = fmt.Errorf("unknown type '%s'", v.Type)
+	}
+
+	return nil, err
+}
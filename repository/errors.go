@@ -1,5 +1,13 @@
 package repository
 
+import (
+	"errors"
+
+	xgoErrors "github.com/anoaland/xgo/errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
 type NotFoundError struct {
 	Message string
 }
@@ -7,3 +15,46 @@ type NotFoundError struct {
 func (e *NotFoundError) Error() string {
 	return e.Message
 }
+
+// HttpStatusCode makes xgo.AsXgoError convert the error to a 404.
+func (e *NotFoundError) HttpStatusCode() int {
+	return fiber.StatusNotFound
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return gorm.ErrRecordNotFound
+}
+
+// ErrInvalidCursor is returned for a tampered, malformed or foreign cursor,
+// e.g. one created with another sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrNoSoftDelete is returned by SoftDelete and Restore for a model without a
+// DeletedAt field.
+var ErrNoSoftDelete = errors.New("model has no DeletedAt field")
+
+// ErrVersionConflict is returned by Update when the row was modified since the
+// version of the payload was read, see optimistic locking in Update.
+var ErrVersionConflict = errors.New("the record was modified by someone else, reload it and try again")
+
+// ErrVersionRequired is returned by Update for a versioned model when the
+// payload has no version.
+var ErrVersionRequired = errors.New("the version of the record is required")
+
+// repositoryError converts err to an XgoError: a 404 for a missing record, a
+// 409 for a version conflict, a duplicated key or a foreign key violation,
+// the latter only reported with gorm.Config.TranslateError, a 428 for a
+// missing version and a 500 otherwise.
+func repositoryError(part string, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, ErrVersionConflict), errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		status = fiber.StatusConflict
+	case errors.Is(err, ErrVersionRequired):
+		status = fiber.StatusPreconditionRequired
+	}
+
+	return xgoErrors.NewHttpError(part, err, status, 1)
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/dto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// keysetColumn is a sort column of a keyset pagination.
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// KeysetPaginate returns a page of M ordered by the sort of pagination,
+// starting after (or, for a PrevCursor, ending before) the row of
+// pagination.Cursor, and sets the NextCursor, PrevCursor and TotalData of
+// pagination. The primary key is appended to the sort to make it unique.
+// Sort columns should not be nullable.
+//
+// The sort must be validated with CursorPagination.Allow, so that clients can
+// neither order by nor read through the cursors the values of other fields,
+// e.g. a password hash. A sort which is not allowed or an invalid cursor
+// returns a bad request XgoError.
+func KeysetPaginate[M interface{}](db *gorm.DB, pagination *dto.CursorPagination, clauses []clause.Expression, joins []string) ([]M, error) {
+	model := new(M)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, xgo.NewHttpInternalError("E_REPO_KEYSET_PAGINATE", err)
+	}
+
+	sorts, ok := pagination.AllowedSort()
+	if !ok {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_KEYSET_SORT", errors.New("the sort is not allowed, see CursorPagination.Allow"))
+	}
+
+	columns, err := keysetColumns(stmt.Schema, sorts)
+	if err != nil {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_KEYSET_SORT", err)
+	}
+
+	signature := keysetSignature(columns)
+	position, err := decodeCursor(pagination.Cursor, signature)
+	if err != nil {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_KEYSET_CURSOR", err)
+	}
+
+	tx := db.Model(model).Clauses(clauses...)
+	for _, join := range joins {
+		tx = tx.Joins(join)
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	pagination.TotalData = nil
+	if !pagination.SkipCount {
+		var totalRows int64
+		if err := tx.Count(&totalRows).Error; err != nil {
+			return nil, xgo.NewHttpInternalError("E_REPO_KEYSET_PAGINATE", err)
+		}
+		pagination.TotalData = &totalRows
+	}
+
+	backward := position != nil && position.Prev
+	query := tx
+	if position != nil {
+		condition, err := keysetCondition(columns, position.Values, backward)
+		if err != nil {
+			return nil, xgo.NewHttpBadRequestError("E_REPO_KEYSET_CURSOR", err)
+		}
+		query = query.Where(condition)
+	}
+
+	for _, column := range columns {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: column.field.DBName},
+			Desc:   column.desc != backward,
+		})
+	}
+
+	limit := pagination.GetLimit()
+	rows := []M{}
+	if err := query.Limit(limit + 1).Find(&rows).Error; err != nil {
+		return nil, xgo.NewHttpInternalError("E_REPO_KEYSET_PAGINATE", err)
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	pagination.NextCursor, pagination.PrevCursor = "", ""
+	if len(rows) > 0 {
+		// going backward, there is always a next page: the one we came from
+		if hasMore || backward {
+			if pagination.NextCursor, err = rowCursor(db, columns, signature, &rows[len(rows)-1], false); err != nil {
+				return nil, xgo.NewHttpInternalError("E_REPO_KEYSET_PAGINATE", err)
+			}
+		}
+
+		if (backward && hasMore) || (!backward && position != nil) {
+			if pagination.PrevCursor, err = rowCursor(db, columns, signature, &rows[0], true); err != nil {
+				return nil, xgo.NewHttpInternalError("E_REPO_KEYSET_PAGINATE", err)
+			}
+		}
+	}
+
+	pagination.Rows = rows
+
+	return rows, nil
+}
+
+// keysetColumns resolves the sort fields and appends the missing primary keys.
+func keysetColumns(s *schema.Schema, sorts []dto.SortField) ([]keysetColumn, error) {
+	var columns []keysetColumn
+	seen := map[string]bool{}
+
+	for _, sort := range sorts {
+		field := lookupSortField(s, sort.Field)
+		if field == nil {
+			return nil, fmt.Errorf("unknown sort field '%s'", sort.Field)
+		}
+
+		if !seen[field.DBName] {
+			seen[field.DBName] = true
+			columns = append(columns, keysetColumn{field: field, desc: sort.Desc})
+		}
+	}
+
+	if len(s.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("%s has no primary key", s.Name)
+	}
+
+	// ties are broken in the direction of the last sort column
+	desc := len(columns) > 0 && columns[len(columns)-1].desc
+	for _, field := range s.PrimaryFields {
+		if !seen[field.DBName] {
+			seen[field.DBName] = true
+			columns = append(columns, keysetColumn{field: field, desc: desc})
+		}
+	}
+
+	return columns, nil
+}
+
+// lookupSortField finds the readable field named name, by its Go or column
+// name, case insensitively, so camelCase query values match too.
+func lookupSortField(s *schema.Schema, name string) *schema.Field {
+	normalized := strings.ReplaceAll(name, "_", "")
+	for _, field := range s.Fields {
+		if field.DBName == "" || !field.Readable {
+			continue
+		}
+
+		if strings.EqualFold(field.Name, name) || strings.EqualFold(field.DBName, name) ||
+			strings.EqualFold(strings.ReplaceAll(field.DBName, "_", ""), normalized) {
+			return field
+		}
+	}
+
+	return nil
+}
+
+func keysetSignature(columns []keysetColumn) string {
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.desc {
+			parts = append(parts, "-"+column.field.DBName)
+		} else {
+			parts = append(parts, column.field.DBName)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// keysetCondition builds `(a > ?) OR (a = ? AND b > ?) OR ...` selecting the
+// rows after the cursor values, or before them when backward.
+func keysetCondition(columns []keysetColumn, encoded []cursorValue, backward bool) (clause.Expression, error) {
+	if len(encoded) != len(columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(encoded))
+	for i, value := range encoded {
+		decoded, err := value.decode()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values[i] = decoded
+	}
+
+	var or []clause.Expression
+	for i, column := range columns {
+		var and []clause.Expression
+		for j := 0; j < i; j++ {
+			and = append(and, clause.Eq{Column: keysetColumnName(columns[j]), Value: values[j]})
+		}
+
+		if column.desc != backward {
+			and = append(and, clause.Lt{Column: keysetColumnName(column), Value: values[i]})
+		} else {
+			and = append(and, clause.Gt{Column: keysetColumnName(column), Value: values[i]})
+		}
+
+		or = append(or, clause.And(and...))
+	}
+
+	return clause.Or(or...), nil
+}
+
+func keysetColumnName(column keysetColumn) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: column.field.DBName}
+}
+
+func rowCursor[M interface{}](db *gorm.DB, columns []keysetColumn, signature string, row *M, prev bool) (string, error) {
+	c := cursor{Sort: signature, Prev: prev}
+	rv := reflect.ValueOf(row).Elem()
+
+	for _, column := range columns {
+		value, _ := column.field.ValueOf(db.Statement.Context, rv)
+		encoded, err := newCursorValue(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, encoded)
+	}
+
+	return encodeCursor(c)
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/dto"
+	"github.com/anoaland/xgo/internal/testdb"
+	"gorm.io/gorm"
+)
+
+type keysetUser struct {
+	ID           uint
+	Name         string
+	Score        int
+	PasswordHash string
+}
+
+var keysetFields = dto.Whitelist{
+	"name":  {Column: "keyset_users.name", Sortable: true},
+	"score": {Column: "keyset_users.score", Sortable: true},
+}
+
+func newKeysetDB(t *testing.T) (*gorm.DB, []keysetUser) {
+	t.Helper()
+
+	SetCursorSecret([]byte("test secret"))
+
+	db := testdb.New(t, &keysetUser{})
+
+	users := []keysetUser{
+		{Name: "d", Score: 2}, {Name: "a", Score: 3}, {Name: "f", Score: 1}, {Name: "b", Score: 2},
+		{Name: "g", Score: 3}, {Name: "c", Score: 2}, {Name: "e", Score: 1},
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db, users
+}
+
+// keysetPage reads the page of cursor sorted by sortBy, as an endpoint would.
+func keysetPage(t *testing.T, db *gorm.DB, sortBy string, cursor string) ([]keysetUser, *dto.CursorPagination) {
+	t.Helper()
+
+	pagination := &dto.CursorPagination{Limit: 3, SortBy: sortBy, Cursor: cursor, SkipCount: true}
+	if err := pagination.Allow(keysetFields); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := KeysetPaginate[keysetUser](db, pagination, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rows, pagination
+}
+
+func names(users []keysetUser) string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestKeysetPaginateRequiresAllowedSort(t *testing.T) {
+	db, _ := newKeysetDB(t)
+
+	_, err := KeysetPaginate[keysetUser](db, &dto.CursorPagination{SortBy: "passwordHash"}, nil, nil)
+	if xgo.AsXgoError(err).HttpErrorCode != 400 {
+		t.Fatalf("expected a sort which is not allowed to be a bad request, got %v", err)
+	}
+
+	if err := (&dto.CursorPagination{SortBy: "passwordHash"}).Allow(keysetFields); xgo.AsXgoError(err).HttpErrorCode != 400 {
+		t.Fatalf("expected a field out of the whitelist to be a bad request, got %v", err)
+	}
+
+	rows, err := KeysetPaginate[keysetUser](db, &dto.CursorPagination{}, nil, nil)
+	if err != nil || len(rows) != 7 {
+		t.Fatalf("expected the rows by primary key without sort, got %d rows (%v)", len(rows), err)
+	}
+}
+
+func TestKeysetPaginateNextAndPrev(t *testing.T) {
+	db, users := newKeysetDB(t)
+
+	// score descending, then name ascending, the primary key breaking no tie
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Score != users[j].Score {
+			return users[i].Score > users[j].Score
+		}
+		return users[i].Name < users[j].Name
+	})
+	want := []string{names(users[0:3]), names(users[3:6]), names(users[6:])}
+
+	var pages []*dto.CursorPagination
+	cursor := ""
+	for i := range want {
+		rows, pagination := keysetPage(t, db, "-score,name", cursor)
+		if got := names(rows); got != want[i] {
+			t.Fatalf("expected the page %d to be %s, got %s", i, want[i], got)
+		}
+
+		if (pagination.PrevCursor == "") != (i == 0) || (pagination.NextCursor == "") != (i == len(want)-1) {
+			t.Fatalf("unexpected cursors of the page %d: next %q, prev %q", i, pagination.NextCursor, pagination.PrevCursor)
+		}
+
+		pages = append(pages, pagination)
+		cursor = pagination.NextCursor
+	}
+
+	for i := len(want) - 1; i > 0; i-- {
+		rows, _ := keysetPage(t, db, "-score,name", pages[i].PrevCursor)
+		if got := names(rows); got != want[i-1] {
+			t.Fatalf("expected the page before %d to be %s, got %s", i, want[i-1], got)
+		}
+	}
+}
+
+func TestKeysetCursorSigning(t *testing.T) {
+	db, _ := newKeysetDB(t)
+	_, first := keysetPage(t, db, "name", "")
+
+	paginate := func(sortBy, cursor string) error {
+		pagination := &dto.CursorPagination{SortBy: sortBy, Cursor: cursor}
+		if err := pagination.Allow(keysetFields); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := KeysetPaginate[keysetUser](db, pagination, nil, nil)
+		return err
+	}
+
+	if err := paginate("name", first.NextCursor); err != nil {
+		t.Fatalf("expected the cursor to be valid, got %v", err)
+	}
+
+	payload, signature, _ := strings.Cut(first.NextCursor, ".")
+	decoded, _ := base64.RawURLEncoding.DecodeString(payload)
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(decoded), `"c"`, `"a"`, 1))) + "." + signature
+
+	for name, test := range map[string]struct{ sortBy, cursor string }{
+		"tampered":     {"name", tampered},
+		"unsigned":     {"name", payload},
+		"garbage":      {"name", "not a cursor"},
+		"another sort": {"-name", first.NextCursor},
+	} {
+		err := paginate(test.sortBy, test.cursor)
+		if !errors.Is(err, ErrInvalidCursor) || xgo.AsXgoError(err).HttpErrorCode != 400 {
+			t.Fatalf("expected a %s cursor to be invalid, got %v", name, err)
+		}
+	}
+
+	SetCursorSecret([]byte("another secret"))
+	defer SetCursorSecret([]byte("test secret"))
+
+	if err := paginate("name", first.NextCursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected a cursor signed with another secret to be invalid, got %v", err)
+	}
+}
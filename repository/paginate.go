@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/dto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const windowCountColumn = "xgo_total_count"
+
+// Page is a page of rows of an offset pagination.
+type Page[T interface{}] struct {
+	Rows       []T   `json:"rows"`
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalData  int64 `json:"totalData"`
+	TotalPages int   `json:"totalPages"`
+}
+
+// MapPage converts the rows of page with fn, e.g. to DTOs.
+func MapPage[M interface{}, T interface{}](page *Page[M], fn func(*M) T) *Page[T] {
+	rows := make([]T, 0, len(page.Rows))
+	for i := range page.Rows {
+		rows = append(rows, fn(&page.Rows[i]))
+	}
+
+	return &Page[T]{Rows: rows, Page: page.Page, Limit: page.Limit, TotalData: page.TotalData, TotalPages: page.TotalPages}
+}
+
+type PaginateOptions struct {
+	// Clauses are additional conditions, besides the filters of the pagination.
+	Clauses []clause.Expression
+
+	// Joins are passed to gorm.DB.Joins, association names or raw joins.
+	Joins []string
+
+	// Preloads are passed to gorm.DB.Preload.
+	Preloads []string
+
+	// Search matches the Keyword of the pagination, ordered by relevance
+	// after the sort of the pagination.
+	// Optional.
+	Search *Search
+
+	// SeparateCount counts the rows with a second query instead of
+	// `COUNT(*) OVER()`, for databases without window functions, e.g. MySQL 5.7.
+	SeparateCount bool
+}
+
+// Paginate returns the page of M of pagination, filtered by the filters
+// validated with Pagination.Allow and sorted by Pagination.GetSort. The rows
+// and their total are read with a single query using `COUNT(*) OVER()`, only
+// a page past the end needs a second query. pagination is not modified. A
+// negative page or limit is a bad request, zero being the default, and the
+// limit is at most dto.MaxLimit.
+func Paginate[M interface{}](db *gorm.DB, pagination *dto.Pagination, options ...PaginateOptions) (*Page[M], error) {
+	var opts PaginateOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	p := *pagination
+	if p.Limit < 0 || p.Page < 0 || p.Page > math.MaxInt32 {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_PAGINATE", errors.New("the page and the limit must be positive"))
+	}
+
+	page := &Page[M]{Rows: []M{}, Page: p.GetPage(), Limit: p.GetLimit()}
+
+	clauses := append(append([]clause.Expression{}, opts.Clauses...), p.Clauses()...)
+	if opts.Search != nil {
+		clauses = append(clauses, opts.Search.Clauses(p.Keyword)...)
+	}
+
+	tx := txWithJoins(db.Model(new(M)).Clauses(clauses...), opts.Joins).Session(&gorm.Session{})
+
+	query := tx.Order(p.GetSort()).Offset(p.GetOffset()).Limit(page.Limit)
+	if opts.Search != nil {
+		query = query.Scopes(opts.Search.OrderScope(p.Keyword))
+	}
+	query = query.Session(&gorm.Session{})
+
+	var err error
+	var total int64
+	if opts.SeparateCount || query.Statement.Distinct {
+		if err = tx.Count(&total).Error; err == nil && total > 0 {
+			err = txWithPeloads(query, opts.Preloads).Find(&page.Rows).Error
+		}
+	} else {
+		total, err = findWithWindowCount(query, opts.Preloads, &page.Rows)
+		if err == nil && len(page.Rows) == 0 && p.GetOffset() > 0 {
+			// past the last page, there is no row to read the count from
+			err = tx.Count(&total).Error
+		}
+	}
+
+	if err != nil {
+		return nil, xgo.NewHttpInternalError("E_REPO_PAGINATE", err)
+	}
+
+	page.TotalData = total
+	page.TotalPages = int(math.Ceil(float64(total) / float64(page.Limit)))
+
+	return page, nil
+}
+
+// findWithWindowCount finds the rows of query into dest, a pointer to a slice,
+// selecting `COUNT(*) OVER()` with them, and returns the count.
+func findWithWindowCount(query *gorm.DB, preloads []string, dest interface{}) (int64, error) {
+	// build the statement as gorm does, then add the count to the selection
+	dryRun := query.Session(&gorm.Session{DryRun: true, Logger: logger.Discard}).Find(dest)
+	if dryRun.Error != nil {
+		return 0, dryRun.Error
+	}
+
+	sql := dryRun.Statement.SQL.String()
+	if !strings.HasPrefix(sql, "SELECT ") {
+		return 0, gorm.ErrInvalidData
+	}
+
+	selection := strings.TrimPrefix(sql, "SELECT ")
+	if strings.HasPrefix(selection, "* ") && dryRun.Statement.Table != "" {
+		// MySQL only accepts a qualified * after another column
+		selection = dryRun.Statement.Quote(dryRun.Statement.Table) + ".*" + selection[1:]
+	}
+
+	tx := query.Set("xgo:window_count", true)
+	tx.Statement.SQL.WriteString("SELECT COUNT(*) OVER() AS " + windowCountColumn + ", " + selection)
+	tx.Statement.Vars = dryRun.Statement.Vars
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	counted := &windowCountRows{Rows: rows}
+	tx.Statement.Dest = dest
+	tx.Statement.ReflectValue = reflect.ValueOf(dest).Elem()
+	gorm.Scan(counted, tx, 0)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	if len(preloads) > 0 {
+		for _, preload := range preloads {
+			tx.Statement.Preloads[preload] = nil
+		}
+		callbacks.Preload(tx)
+	}
+	callbacks.AfterQuery(tx)
+
+	return counted.total, tx.Error
+}
+
+// windowCountRows scans the window count column, the first one, apart from
+// the columns of the model.
+type windowCountRows struct {
+	gorm.Rows
+	total int64
+}
+
+func (r *windowCountRows) Scan(dest ...interface{}) error {
+	if len(dest) > 0 {
+		dest = append([]interface{}{&r.total}, dest[1:]...)
+	}
+
+	return r.Rows.Scan(dest...)
+}
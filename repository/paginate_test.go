@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/dto"
+	"github.com/anoaland/xgo/internal/testdb"
+)
+
+func TestPaginateLimits(t *testing.T) {
+	db := testdb.New(t, &uowItem{})
+
+	items := make([]uowItem, dto.MaxLimit+1)
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pagination := range []dto.Pagination{{Limit: -1}, {Page: -1}, {Page: 1 << 40}} {
+		if _, err := Paginate[uowItem](db, &pagination); xgo.AsXgoError(err).HttpErrorCode != 400 {
+			t.Fatalf("expected a bad request for %+v, got %v", pagination, err)
+		}
+	}
+
+	page, err := Paginate[uowItem](db, &dto.Pagination{Limit: 1 << 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Limit != dto.MaxLimit || len(page.Rows) != dto.MaxLimit || page.TotalPages != 2 {
+		t.Fatalf("expected the limit to be clamped to %d, got %d rows of %+v", dto.MaxLimit, len(page.Rows), page)
+	}
+}
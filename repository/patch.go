@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/anoaland/xgo"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Patch is a partial update, the values of the fields to set by name: the
+// Go, column or JSON name of the model field. A nil value sets NULL.
+// See Repository.Patch.
+type Patch map[string]interface{}
+
+// PatchFields returns the patch of the non-nil pointer fields of the struct
+// dto, e.g. a `Name *string` field is only updated when present in the request.
+func PatchFields(dto interface{}) Patch {
+	patch := Patch{}
+	value := reflect.Indirect(reflect.ValueOf(dto))
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() || field.Type.Kind() != reflect.Pointer || value.Field(i).IsNil() {
+			continue
+		}
+
+		if name := patchFieldName(field); name != "" {
+			patch[name] = value.Field(i).Elem().Interface()
+		}
+	}
+
+	return patch
+}
+
+// PatchMask returns the patch of the fields of the struct dto listed in mask,
+// by Go or JSON name, including zero values, e.g. for `?fields=name,active`.
+func PatchMask(dto interface{}, mask ...string) (Patch, error) {
+	patch := Patch{}
+	value := reflect.Indirect(reflect.ValueOf(dto))
+
+	for _, masked := range mask {
+		found := false
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := patchFieldName(field)
+			if !field.IsExported() || name == "" || (!strings.EqualFold(field.Name, masked) && name != masked) {
+				continue
+			}
+
+			fieldValue := value.Field(i)
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					patch[name] = nil
+					found = true
+					break
+				}
+				fieldValue = fieldValue.Elem()
+			}
+
+			patch[name] = fieldValue.Interface()
+			found = true
+			break
+		}
+
+		if !found {
+			return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", fmt.Errorf("unknown field '%s'", masked))
+		}
+	}
+
+	return patch, nil
+}
+
+// MergePatch returns the patch of a JSON Merge Patch document (RFC 7396),
+// e.g. `{"name": "new", "nickname": null}`. Nested objects replace the value
+// of the field, e.g. a JSON column.
+func MergePatch(document []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(document, &patch); err != nil || patch == nil {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", errors.New("a merge patch must be a JSON object"))
+	}
+
+	return patch, nil
+}
+
+// JsonPatch returns the patch of a JSON Patch document (RFC 6902) with
+// `add`, `replace` and `remove` operations on top level fields, e.g.
+// `[{"op": "replace", "path": "/name", "value": "new"}]`.
+func JsonPatch(document []byte) (Patch, error) {
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(document, &operations); err != nil {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", errors.New("a JSON patch must be an array of operations"))
+	}
+
+	patch := Patch{}
+	for _, operation := range operations {
+		name := strings.TrimPrefix(operation.Path, "/")
+		if !strings.HasPrefix(operation.Path, "/") || name == "" || strings.Contains(name, "/") {
+			return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", fmt.Errorf("unsupported path '%s', only top level fields can be patched", operation.Path))
+		}
+		name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+
+		switch operation.Op {
+		case "add", "replace":
+			var value interface{}
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", fmt.Errorf("invalid value of '%s'", operation.Path))
+			}
+			patch[name] = value
+		case "remove":
+			patch[name] = nil
+		default:
+			return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", fmt.Errorf("unsupported operation '%s'", operation.Op))
+		}
+	}
+
+	return patch, nil
+}
+
+// patchFieldName returns the JSON name of a DTO field, or its Go name.
+func patchFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+
+	return name
+}
+
+// columns resolves the fields of the patch and converts their values to the
+// field types. The primary key, the version and the creation time can not be
+// patched, and when updatable is set, only its fields.
+func (p Patch) columns(s *schema.Schema, updatable []string) (map[string]interface{}, error) {
+	allowed := map[*schema.Field]bool{}
+	for _, name := range updatable {
+		if field := lookupPatchField(s, name); field != nil {
+			allowed[field] = true
+		}
+	}
+
+	version := versionField(s)
+	columns := map[string]interface{}{}
+	for name, value := range p {
+		field := lookupPatchField(s, name)
+		if field == nil {
+			return nil, fmt.Errorf("unknown field '%s'", name)
+		}
+
+		if field == version {
+			continue
+		}
+
+		if field.PrimaryKey || field.AutoCreateTime != 0 || !field.Updatable || (len(updatable) > 0 && !allowed[field]) {
+			return nil, fmt.Errorf("'%s' can not be updated, updatable fields: %s", name, updatableNames(s, updatable))
+		}
+
+		converted, err := convertPatchValue(field, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of '%s': %w", name, err)
+		}
+		columns[field.DBName] = converted
+	}
+
+	return columns, nil
+}
+
+// lookupPatchField finds the field of s by its Go, column or JSON name.
+func lookupPatchField(s *schema.Schema, name string) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Name == name || field.DBName == name || (jsonName != "" && jsonName == name) {
+			return field
+		}
+	}
+
+	return lookupSortField(s, name)
+}
+
+func updatableNames(s *schema.Schema, updatable []string) string {
+	if len(updatable) > 0 {
+		names := append([]string{}, updatable...)
+		sort.Strings(names)
+		return strings.Join(names, ", ")
+	}
+
+	var names []string
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.Updatable && !field.PrimaryKey && field.AutoCreateTime == 0 && field != versionField(s) {
+			names = append(names, field.Name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// convertPatchValue converts value, e.g. a JSON number or a date string, to
+// the type of field.
+func convertPatchValue(field *schema.Field, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if reflect.TypeOf(value) == field.FieldType {
+		return value, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, converted.Interface()); err != nil {
+		return nil, fmt.Errorf("expected %s", field.FieldType)
+	}
+
+	return converted.Elem().Interface(), nil
+}
+
+// version returns the condition on the expected version of a versioned model,
+// nil for a model without version, or ErrVersionRequired.
+func (p Patch) version(s *schema.Schema) (clause.Expression, error) {
+	field := versionField(s)
+	if field == nil {
+		return nil, nil
+	}
+
+	for name, value := range p {
+		if lookupPatchField(s, name) != field {
+			continue
+		}
+
+		expected, err := convertPatchValue(field, value)
+		if err != nil || expected == nil || reflect.ValueOf(expected).IsZero() {
+			return nil, ErrVersionRequired
+		}
+
+		return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: expected}, nil
+	}
+
+	return nil, ErrVersionRequired
+}
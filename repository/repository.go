@@ -1,14 +1,24 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"time"
 
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/db/replica"
+	"github.com/anoaland/xgo/dto"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type Repository[M interface{}, D IDto[M, D], DList IDto[M, DList], DCreate ICreateDto[M], DUpdate IUpdateDto[M, D]] struct {
-	db *gorm.DB
+	db        *gorm.DB
+	search    *Search
+	updatable []string
 }
 
 type BriefRepository[M interface{}, D IDto[M, D], DCreate ICreateDto[M]] struct {
@@ -23,6 +33,30 @@ func Brief[M interface{}, D IDto[M, D], DCreate ICreateDto[M]](context *gorm.DB)
 	}
 }
 
+func (r *BriefRepository[M, D, DCreate]) UsePrimary() *BriefRepository[M, D, DCreate] {
+	return &BriefRepository[M, D, DCreate]{Repository: r.Repository.UsePrimary()}
+}
+
+// Tx returns a repository using the transaction tx, e.g. UnitOfWork.DB.
+func (r *BriefRepository[M, D, DCreate]) Tx(tx *gorm.DB) *BriefRepository[M, D, DCreate] {
+	return &BriefRepository[M, D, DCreate]{Repository: r.Repository.Tx(tx)}
+}
+
+// WithContext returns a repository running its statements with ctx, see Repository.WithContext.
+func (r *BriefRepository[M, D, DCreate]) WithContext(ctx context.Context) *BriefRepository[M, D, DCreate] {
+	return &BriefRepository[M, D, DCreate]{Repository: r.Repository.WithContext(ctx)}
+}
+
+// WithSearch returns a repository searching keywords with search.
+func (r *BriefRepository[M, D, DCreate]) WithSearch(search Search) *BriefRepository[M, D, DCreate] {
+	return &BriefRepository[M, D, DCreate]{Repository: r.Repository.WithSearch(search)}
+}
+
+// WithUpdatable returns a repository patching only fields, see Repository.WithUpdatable.
+func (r *BriefRepository[M, D, DCreate]) WithUpdatable(fields ...string) *BriefRepository[M, D, DCreate] {
+	return &BriefRepository[M, D, DCreate]{Repository: r.Repository.WithUpdatable(fields...)}
+}
+
 func New[M interface{}, D IDto[M, D], DList IDto[M, DList], DCreate ICreateDto[M], DUpdate IUpdateDto[M, D]](context *gorm.DB) *Repository[M, D, DList, DCreate, DUpdate] {
 	return &Repository[M, D, DList, DCreate, DUpdate]{
 		db: context,
@@ -30,14 +64,55 @@ func New[M interface{}, D IDto[M, D], DList IDto[M, DList], DCreate ICreateDto[M
 }
 
 func (r *Repository[M, D, DList, DCreate, DUpdate]) Tx(tx *gorm.DB) *Repository[M, D, DList, DCreate, DUpdate] {
-	return New[M, D, DList, DCreate, DUpdate](tx)
+	return r.with(tx)
+}
+
+// UsePrimary returns a repository reading from the primary database when read
+// replicas are registered, e.g. to read your own writes. See replica.UsePrimary.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) UsePrimary() *Repository[M, D, DList, DCreate, DUpdate] {
+	return r.with(replica.UsePrimary(r.db))
+}
+
+// WithContext returns a repository running its statements with ctx, e.g.
+// xgo.RequestContext, so that their logs and audit entries carry the request
+// id and the authenticated user.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) WithContext(ctx context.Context) *Repository[M, D, DList, DCreate, DUpdate] {
+	return r.with(r.db.WithContext(ctx))
+}
+
+// WithSearch returns a repository searching keywords with search, e.g. with
+// a PostgresFullTextSearch on the name and description columns.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) WithSearch(search Search) *Repository[M, D, DList, DCreate, DUpdate] {
+	copied := *r
+	copied.search = &search
+	return &copied
+}
+
+// WithUpdatable returns a repository whose Patch only updates fields, by
+// Go, column or JSON name, e.g. to keep an owner or a status out of reach of
+// a PATCH endpoint. All the fields are updatable when none is given.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) WithUpdatable(fields ...string) *Repository[M, D, DList, DCreate, DUpdate] {
+	copied := *r
+	copied.updatable = fields
+	return &copied
+}
+
+// with returns a copy of the repository using db.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) with(db *gorm.DB) *Repository[M, D, DList, DCreate, DUpdate] {
+	copied := *r
+	copied.db = db
+	return &copied
 }
 
 func (r *Repository[M, D, DList, DCreate, DUpdate]) Create(payload DCreate) (*D, error) {
 	values := payload.ToModel()
+	if err := initVersion(r.db, values); err != nil {
+		return nil, repositoryError("E_REPO_CREATE", err)
+	}
+
 	err := r.db.Create(&values).Error
 	if err != nil {
-		return nil, err
+		return nil, repositoryError("E_REPO_CREATE", err)
 	}
 
 	var d D
@@ -45,48 +120,366 @@ func (r *Repository[M, D, DList, DCreate, DUpdate]) Create(payload DCreate) (*D,
 	return res, nil
 }
 
+// CreateInBatches inserts payloads batchSize rows per statement.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) CreateInBatches(payloads []DCreate, batchSize int) ([]D, error) {
+	values := make([]*M, 0, len(payloads))
+	for _, payload := range payloads {
+		values = append(values, payload.ToModel())
+	}
+
+	if len(values) == 0 {
+		return []D{}, nil
+	}
+
+	if err := initVersion(r.db, values...); err != nil {
+		return nil, repositoryError("E_REPO_CREATE_IN_BATCHES", err)
+	}
+
+	if err := r.db.CreateInBatches(values, batchSize).Error; err != nil {
+		return nil, repositoryError("E_REPO_CREATE_IN_BATCHES", err)
+	}
+
+	results := make([]D, 0, len(values))
+	for _, value := range values {
+		var d D
+		results = append(results, *d.FromModel(value))
+	}
+
+	return results, nil
+}
+
+// Upsert inserts payload, or updates the conflicting row of conflictColumns,
+// e.g. a unique email, with updateColumns, all the columns when empty. The
+// version of a versioned model is incremented, without being checked.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Upsert(payload DCreate, conflictColumns []string, updateColumns ...string) (*D, error) {
+	s, err := modelSchema[M](r.db)
+	if err != nil {
+		return nil, repositoryError("E_REPO_UPSERT", err)
+	}
+
+	onConflict := clause.OnConflict{UpdateAll: len(updateColumns) == 0}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if field := versionField(s); field != nil {
+		onConflict.UpdateAll = false
+		onConflict.DoUpdates = versionedUpserts(s, field, updateColumns)
+	} else if len(updateColumns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+
+	values := payload.ToModel()
+	if err := initVersion(r.db, values); err != nil {
+		return nil, repositoryError("E_REPO_UPSERT", err)
+	}
+
+	if err := r.db.Clauses(onConflict).Create(values).Error; err != nil {
+		return nil, repositoryError("E_REPO_UPSERT", err)
+	}
+
+	if versionField(s) != nil {
+		// reload the incremented version of an updated row
+		rv := reflect.ValueOf(values).Elem()
+		tx := r.db.Model(new(M))
+		for _, column := range conflictColumns {
+			if field := s.LookUpField(column); field != nil {
+				value, _ := field.ValueOf(r.db.Statement.Context, rv)
+				tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+			}
+		}
+
+		if err := tx.First(values).Error; err != nil {
+			return nil, repositoryError("E_REPO_UPSERT", err)
+		}
+	}
+
+	var d D
+	return d.FromModel(values), nil
+}
+
 func (r *Repository[M, D, DList, DCreate, DUpdate]) CreateRaw(payload DCreate) (*M, error) {
 	return r.CreateRawInTransaction(r.db, payload)
 }
 
 func (*Repository[M, D, DList, DCreate, DUpdate]) CreateRawInTransaction(db *gorm.DB, payload DCreate) (*M, error) {
 	values := payload.ToModel()
+	if err := initVersion(db, values); err != nil {
+		return nil, repositoryError("E_REPO_CREATE", err)
+	}
+
 	err := db.Create(&values).Error
 	if err != nil {
-		return nil, err
+		return nil, repositoryError("E_REPO_CREATE", err)
 	}
 
 	return values, nil
 }
 
+// Update updates the matching rows with the non-zero fields of payload.
+//
+// A model with an integer field tagged `gorm:"version"` is updated with
+// optimistic locking: the version of payload must be the one of the row, it
+// is incremented by the update. Update returns ErrVersionConflict, a 409, when the row was modified
+// in the meantime and ErrVersionRequired, a 428, when payload has no version.
+// See xgo.IfMatchVersion to read the version from the If-Match header.
 func (r *Repository[M, D, DList, DCreate, DUpdate]) Update(payload DUpdate, whereQuery interface{}, whereArgs ...interface{}) error {
 	return r.UpdateInTransaction(r.db, payload, whereQuery, whereArgs...)
 }
 
 func (*Repository[M, D, DList, DCreate, DUpdate]) UpdateInTransaction(db *gorm.DB, payload DUpdate, whereQuery interface{}, whereArgs ...interface{}) error {
 	values := payload.ToModel()
-	err := db.Model(&values).Where(whereQuery, whereArgs...).Updates(&values).Error
+	version, err := expectVersion(db, values)
 	if err != nil {
-		return err
+		return repositoryError("E_REPO_UPDATE", err)
+	}
+
+	tx := db.Model(&values).Where(whereQuery, whereArgs...)
+	if version != nil {
+		tx = tx.Where(version)
+	}
+
+	result := tx.Updates(&values)
+	if result.Error != nil {
+		return repositoryError("E_REPO_UPDATE", result.Error)
+	}
+
+	if version != nil && result.RowsAffected == 0 {
+		var count int64
+		if err := db.Model(new(M)).Where(whereQuery, whereArgs...).Count(&count).Error; err != nil {
+			return repositoryError("E_REPO_UPDATE", err)
+		}
+
+		if count == 0 {
+			return &NotFoundError{Message: "Record Not Found"}
+		}
+
+		return repositoryError("E_REPO_UPDATE", ErrVersionConflict)
 	}
 
 	return nil
 }
 
+// Patch updates the fields of patch, including zero values and NULLs, of the
+// row matching whereQuery and returns it updated. Unlike Update, only the fields
+// present in the request are set, see PatchFields, PatchMask, MergePatch and
+// JsonPatch. Fields which are unknown or not updatable, see WithUpdatable, are
+// rejected with a 400.
+//
+// The version of a versioned model is expected in patch, as with Update.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Patch(patch Patch, whereQuery interface{}, whereArgs ...interface{}) (*D, error) {
+	s, err := modelSchema[M](r.db)
+	if err != nil {
+		return nil, repositoryError("E_REPO_PATCH", err)
+	}
+
+	columns, err := patch.columns(s, r.updatable)
+	if err != nil {
+		return nil, xgo.NewHttpBadRequestError("E_REPO_PATCH", err)
+	}
+
+	version, err := patch.version(s)
+	if err != nil {
+		return nil, repositoryError("E_REPO_PATCH", err)
+	}
+
+	value := new(M)
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if len(columns) > 0 || version != nil {
+			update := tx.Model(new(M)).Where(whereQuery, whereArgs...)
+			if version != nil {
+				update = update.Where(version)
+				columns[versionField(s).DBName] = gorm.Expr("? + 1", clause.Column{Table: clause.CurrentTable, Name: versionField(s).DBName})
+			}
+
+			result := update.Updates(columns)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if version != nil && result.RowsAffected == 0 {
+				var count int64
+				if err := tx.Model(new(M)).Where(whereQuery, whereArgs...).Count(&count).Error; err != nil {
+					return err
+				}
+
+				if count > 0 {
+					return ErrVersionConflict
+				}
+			}
+		}
+
+		return tx.Where(whereQuery, whereArgs...).First(value).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &NotFoundError{Message: "Record Not Found"}
+	}
+
+	if err != nil {
+		return nil, repositoryError("E_REPO_PATCH", err)
+	}
+
+	var d D
+	return d.FromModel(value), nil
+}
+
+// SoftDelete sets the DeletedAt field of the matching rows, a gorm.DeletedAt
+// or a time. It returns ErrNoSoftDelete for a model without one, see Delete.
 func (r *Repository[M, D, DList, DCreate, DUpdate]) SoftDelete(whereQuery interface{}, whereArgs ...interface{}) error {
+	field, err := r.deletedAtField()
+	if err != nil {
+		return err
+	}
+
 	model := new(M)
-	now := time.Now().UTC()
-	err := r.db.Model(model).Where(whereQuery, whereArgs...).Update("DeletedAt", now).Error
+	if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+		err = r.db.Where(whereQuery, whereArgs...).Delete(model).Error
+	} else {
+		now := time.Now().UTC()
+		err = r.db.Model(model).Where(whereQuery, whereArgs...).Update(field.DBName, now).Error
+	}
+
+	if err != nil {
+		return repositoryError("E_REPO_SOFT_DELETE", err)
+	}
+
+	return nil
+}
+
+// Restore clears the DeletedAt field of the matching soft deleted rows.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Restore(whereQuery interface{}, whereArgs ...interface{}) error {
+	field, err := r.deletedAtField()
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Unscoped().Model(new(M)).Where(whereQuery, whereArgs...).Update(field.DBName, nil).Error
+	if err != nil {
+		return repositoryError("E_REPO_RESTORE", err)
+	}
+
+	return nil
+}
+
+// Delete permanently deletes the matching rows, soft deleted or not.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Delete(whereQuery interface{}, whereArgs ...interface{}) error {
+	err := r.db.Unscoped().Where(whereQuery, whereArgs...).Delete(new(M)).Error
+	if err != nil {
+		return repositoryError("E_REPO_DELETE", err)
+	}
 
-	return err
+	return nil
+}
+
+func (r *Repository[M, D, DList, DCreate, DUpdate]) deletedAtField() (*schema.Field, error) {
+	s, err := modelSchema[M](r.db)
+	if err != nil {
+		return nil, repositoryError("E_REPO_SOFT_DELETE", err)
+	}
+
+	field := s.LookUpField("DeletedAt")
+	if field == nil {
+		return nil, repositoryError("E_REPO_SOFT_DELETE", ErrNoSoftDelete)
+	}
+
+	return field, nil
 }
 
 func (r *Repository[M, D, DList, DCreate, DUpdate]) FindAll(conds interface{}, orderBy interface{}, args ...interface{}) ([]DList, error) {
-	model := new(*M)
-	var rows *[]M
-	r.db.Model(&model).Where(conds, args...).Order(orderBy).Find(&rows)
+	var rows []M
+	tx := r.db.Model(new(M))
+	if conds != nil {
+		tx = tx.Where(conds, args...)
+	}
+
+	if err := tx.Order(orderBy).Find(&rows).Error; err != nil {
+		return nil, repositoryError("E_REPO_FIND_ALL", err)
+	}
+
+	return r.MapList(&rows), nil
+}
+
+// Paginate returns the page of pagination, searching its Keyword with the
+// search of WithSearch. See the Paginate function.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Paginate(pagination *dto.Pagination, options ...PaginateOptions) (*Page[DList], error) {
+	var opts PaginateOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	if opts.Search == nil {
+		opts.Search = r.search
+	}
+
+	page, err := Paginate[M](r.db, pagination, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return MapPage(page, func(row *M) DList {
+		var d DList
+		return *d.FromModel(row)
+	}), nil
+}
+
+// Count returns the number of matching rows, all rows when conds is nil.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Count(conds interface{}, args ...interface{}) (int64, error) {
+	tx := r.db.Model(new(M))
+	if conds != nil {
+		tx = tx.Where(conds, args...)
+	}
+
+	var count int64
+	if err := tx.Count(&count).Error; err != nil {
+		return 0, repositoryError("E_REPO_COUNT", err)
+	}
+
+	return count, nil
+}
+
+// Exists reports whether a row matches, reading at most one row.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) Exists(conds interface{}, args ...interface{}) (bool, error) {
+	tx := r.db.Model(new(M)).Select("1")
+	if conds != nil {
+		tx = tx.Where(conds, args...)
+	}
+
+	var found []int
+	if err := tx.Limit(1).Scan(&found).Error; err != nil {
+		return false, repositoryError("E_REPO_EXISTS", err)
+	}
+
+	return len(found) > 0, nil
+}
+
+// CursorPaginate returns a page of rows matching clauses with keyset
+// pagination, and sets pagination.Rows to it. See KeysetPaginate.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) CursorPaginate(pagination *dto.CursorPagination, clauses ...clause.Expression) ([]DList, error) {
+	rows, err := KeysetPaginate[M](r.db, pagination, clauses, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := r.MapList(&rows)
+	pagination.Rows = results
+
+	return results, nil
+}
+
+// FindAllByKeyword returns the rows matching keyword and clauses, ordered by
+// relevance. It requires a search configured with WithSearch.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) FindAllByKeyword(keyword string, clauses ...clause.Expression) ([]DList, error) {
+	if r.search == nil {
+		return nil, xgo.NewHttpInternalError("E_REPO_SEARCH", errors.New("no search configured, see WithSearch"))
+	}
+
+	var rows []M
+	err := r.db.Model(new(M)).Clauses(clauses...).Scopes(r.search.Scope(keyword)).Find(&rows).Error
+	if err != nil {
+		return nil, xgo.NewHttpInternalError("E_REPO_SEARCH", err)
+	}
 
-	return r.MapList(rows), nil
+	return r.MapList(&rows), nil
 }
 
 func (r *Repository[M, D, DList, DCreate, DUpdate]) MapList(rows *[]M) []DList {
@@ -100,18 +493,38 @@ func (r *Repository[M, D, DList, DCreate, DUpdate]) MapList(rows *[]M) []DList {
 }
 
 func (r *Repository[M, D, DList, DCreate, DUpdate]) FindOne(conds ...interface{}) (*D, error) {
-	var value *M
-	result := r.db.First(&value, conds)
+	value := new(M)
+	result := r.db.First(value, conds...)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, &NotFoundError{Message: "Record Not Found"}
 	}
 
+	if result.Error != nil {
+		return nil, repositoryError("E_REPO_FIND_ONE", result.Error)
+	}
+
 	var d D
 	res := d.FromModel(value)
 	return res, nil
 }
 
+// FindByID returns the row with the primary key id, or a NotFoundError.
+func (r *Repository[M, D, DList, DCreate, DUpdate]) FindByID(id interface{}) (*D, error) {
+	s, err := modelSchema[M](r.db)
+	if err != nil {
+		return nil, repositoryError("E_REPO_FIND_BY_ID", err)
+	}
+
+	if s.PrioritizedPrimaryField == nil {
+		return nil, repositoryError("E_REPO_FIND_BY_ID", fmt.Errorf("%s has no single primary key", s.Name))
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName}
+
+	return r.FindOne(clause.Eq{Column: column, Value: id})
+}
+
 type IDto[M interface{}, D interface{}] interface {
 	ToModel() *M
 	FromModel(*M) *D // IDto[M]
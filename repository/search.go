@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anoaland/xgo/dto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchColumn is a column searched by keyword.
+type SearchColumn struct {
+	// Column is written as is in the statement, e.g. "users.name".
+	Column string
+
+	// Weight ranks the matches of the column, higher first.
+	// Optional. Default: 1.
+	Weight float64
+}
+
+// SearchStrategy turns a keyword into a condition and a relevance.
+type SearchStrategy interface {
+	// Condition returns the condition of the rows matching keyword.
+	Condition(columns []SearchColumn, keyword string) clause.Expression
+	// Rank returns the relevance of a row, higher first, or nil.
+	Rank(columns []SearchColumn, keyword string) clause.Expression
+}
+
+// Search configures the keyword search of a repository, see Repository.WithSearch.
+type Search struct {
+	// Strategy matches the keyword.
+	// Optional. Default: LikeSearch.
+	Strategy SearchStrategy
+
+	Columns []SearchColumn
+
+	// DisableRank keeps the order of the query instead of ordering by relevance.
+	DisableRank bool
+}
+
+func (s Search) strategy() SearchStrategy {
+	if s.Strategy == nil {
+		return LikeSearch{}
+	}
+
+	return s.Strategy
+}
+
+// Condition returns the condition of the rows matching keyword, or nil for an
+// empty keyword.
+func (s Search) Condition(keyword string) clause.Expression {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" || len(s.Columns) == 0 {
+		return nil
+	}
+
+	return s.strategy().Condition(s.Columns, keyword)
+}
+
+// Clauses returns the condition of keyword as clauses, e.g. for FilterPaginate.
+func (s Search) Clauses(keyword string) []clause.Expression {
+	if condition := s.Condition(keyword); condition != nil {
+		return []clause.Expression{condition}
+	}
+
+	return nil
+}
+
+// Scope filters the rows matching keyword, ordered by relevance, see
+// OrderScope. An empty keyword returns all rows.
+func (s Search) Scope(keyword string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if condition := s.Condition(keyword); condition != nil {
+			db = db.Where(condition)
+		}
+
+		return s.OrderScope(keyword)(db)
+	}
+}
+
+// OrderScope orders the rows by relevance of keyword, after the order of the
+// query if any, unless DisableRank is set.
+func (s Search) OrderScope(keyword string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" || len(s.Columns) == 0 || s.DisableRank {
+			return db
+		}
+
+		rank := s.strategy().Rank(s.Columns, keyword)
+		if rank == nil {
+			return db
+		}
+
+		var order clause.Expression = clause.Expr{SQL: "? DESC", Vars: []interface{}{rank}, WithoutParentheses: true}
+		if existing, ok := db.Statement.Clauses["ORDER BY"].Expression.(clause.OrderBy); ok {
+			order = clause.Expr{SQL: "?, ?", Vars: []interface{}{orderList{existing}, order}, WithoutParentheses: true}
+		}
+
+		return db.Clauses(clause.OrderBy{Expression: order})
+	}
+}
+
+// orderList builds the columns of an ORDER BY without its keyword.
+type orderList struct {
+	orderBy clause.OrderBy
+}
+
+func (l orderList) Build(builder clause.Builder) {
+	l.orderBy.Build(builder)
+}
+
+func searchColumn(column SearchColumn) clause.Column {
+	return clause.Column{Name: column.Column, Raw: true}
+}
+
+func searchWeight(column SearchColumn) float64 {
+	if column.Weight <= 0 {
+		return 1
+	}
+
+	return column.Weight
+}
+
+// weightedSum returns `(CASE WHEN <match> THEN <weight> ELSE 0 END + ...)`.
+func weightedSum(columns []SearchColumn, match func(column SearchColumn) clause.Expr) clause.Expression {
+	var sql []string
+	var vars []interface{}
+	for _, column := range columns {
+		sql = append(sql, fmt.Sprintf("CASE WHEN ? THEN %g ELSE 0 END", searchWeight(column)))
+		vars = append(vars, match(column))
+	}
+
+	return clause.Expr{SQL: "(" + strings.Join(sql, " + ") + ")", Vars: vars}
+}
+
+// LikeSearch matches `LOWER(column) LIKE %keyword%` on any column, the
+// wildcards of the keyword being escaped. It works on every database but can
+// not use regular indexes.
+type LikeSearch struct{}
+
+func (LikeSearch) match(column SearchColumn, keyword string) clause.Expr {
+	return clause.Expr{SQL: "LOWER(?) LIKE ? ESCAPE '!'", Vars: []interface{}{searchColumn(column), "%" + dto.EscapeLike(strings.ToLower(keyword)) + "%"}}
+}
+
+func (s LikeSearch) Condition(columns []SearchColumn, keyword string) clause.Expression {
+	var or []clause.Expression
+	for _, column := range columns {
+		or = append(or, s.match(column, keyword))
+	}
+
+	return clause.Or(or...)
+}
+
+func (s LikeSearch) Rank(columns []SearchColumn, keyword string) clause.Expression {
+	return weightedSum(columns, func(column SearchColumn) clause.Expr {
+		return s.match(column, keyword)
+	})
+}
+
+var textSearchConfig = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// PostgresFullTextSearch matches `websearch_to_tsquery` queries, e.g.
+// `"exact phrase" -excluded or other`, ranked with `ts_rank`. Up to four
+// distinct column weights are mapped to the tsvector weights A to D.
+type PostgresFullTextSearch struct {
+	// Config is the text search configuration.
+	// Optional. Default: simple.
+	Config string
+
+	// Vector is a tsvector column, e.g. a generated column with a GIN index,
+	// searched instead of the vector built from the columns, which can only
+	// use an expression index of the exact same expression.
+	// Optional.
+	Vector string
+}
+
+func (s PostgresFullTextSearch) config() string {
+	if !textSearchConfig.MatchString(s.Config) {
+		return "simple"
+	}
+
+	return s.Config
+}
+
+func (s PostgresFullTextSearch) query(keyword string) clause.Expr {
+	return clause.Expr{SQL: fmt.Sprintf("websearch_to_tsquery('%s', ?)", s.config()), Vars: []interface{}{keyword}}
+}
+
+// labels maps the columns to the tsvector weights, by decreasing weight.
+func (s PostgresFullTextSearch) labels(columns []SearchColumn) (map[float64]string, []float64) {
+	var weights []float64
+	seen := map[float64]bool{}
+	for _, column := range columns {
+		if weight := searchWeight(column); !seen[weight] {
+			seen[weight] = true
+			weights = append(weights, weight)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(weights)))
+
+	labels := map[float64]string{}
+	// ts_rank takes the weights of D, C, B and A
+	rankWeights := []float64{0, 0, 0, 0}
+	for i, weight := range weights {
+		label := i
+		if label > 3 {
+			label = 3
+		}
+		labels[weight] = string(rune('A' + label))
+		if rankWeights[3-label] == 0 {
+			rankWeights[3-label] = weight / weights[0]
+		}
+	}
+
+	return labels, rankWeights
+}
+
+func (s PostgresFullTextSearch) vector(columns []SearchColumn) clause.Expr {
+	if s.Vector != "" {
+		return clause.Expr{SQL: "?", Vars: []interface{}{clause.Column{Name: s.Vector, Raw: true}}}
+	}
+
+	labels, _ := s.labels(columns)
+
+	var sql []string
+	var vars []interface{}
+	for _, column := range columns {
+		sql = append(sql, fmt.Sprintf("setweight(to_tsvector('%s', coalesce(?::text, '')), '%s')", s.config(), labels[searchWeight(column)]))
+		vars = append(vars, searchColumn(column))
+	}
+
+	return clause.Expr{SQL: "(" + strings.Join(sql, " || ") + ")", Vars: vars}
+}
+
+func (s PostgresFullTextSearch) Condition(columns []SearchColumn, keyword string) clause.Expression {
+	return clause.Expr{SQL: "? @@ ?", Vars: []interface{}{s.vector(columns), s.query(keyword)}}
+}
+
+func (s PostgresFullTextSearch) Rank(columns []SearchColumn, keyword string) clause.Expression {
+	_, weights := s.labels(columns)
+
+	return clause.Expr{
+		SQL:  fmt.Sprintf("ts_rank('{%g,%g,%g,%g}', ?, ?)", weights[0], weights[1], weights[2], weights[3]),
+		Vars: []interface{}{s.vector(columns), s.query(keyword)},
+	}
+}
+
+// SqlServerContainsSearch matches the words of the keyword as prefixes with
+// `CONTAINS`, which requires a full-text index on the columns. Rows are
+// ranked by the total weight of the matching columns.
+type SqlServerContainsSearch struct{}
+
+// condition returns the CONTAINS search condition of keyword, e.g.
+// `"word1*" AND "word2*"`.
+func (SqlServerContainsSearch) condition(keyword string) string {
+	var terms []string
+	for _, word := range strings.Fields(keyword) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word != "" {
+			terms = append(terms, `"`+word+`*"`)
+		}
+	}
+
+	return strings.Join(terms, " AND ")
+}
+
+func (s SqlServerContainsSearch) Condition(columns []SearchColumn, keyword string) clause.Expression {
+	var list []string
+	var vars []interface{}
+	for _, column := range columns {
+		list = append(list, "?")
+		vars = append(vars, searchColumn(column))
+	}
+
+	return clause.Expr{SQL: "CONTAINS((" + strings.Join(list, ", ") + "), ?)", Vars: append(vars, s.condition(keyword))}
+}
+
+func (s SqlServerContainsSearch) Rank(columns []SearchColumn, keyword string) clause.Expression {
+	condition := s.condition(keyword)
+
+	return weightedSum(columns, func(column SearchColumn) clause.Expr {
+		return clause.Expr{SQL: "CONTAINS(?, ?)", Vars: []interface{}{searchColumn(column), condition}}
+	})
+}
+
+// PostgresTrigramSearch matches columns containing a word similar to the
+// keyword with the `<%` operator of the pg_trgm extension, tolerating typos,
+// ranked by `word_similarity`. A GIN index with gin_trgm_ops speeds it up.
+type PostgresTrigramSearch struct{}
+
+func (PostgresTrigramSearch) Condition(columns []SearchColumn, keyword string) clause.Expression {
+	var or []clause.Expression
+	for _, column := range columns {
+		or = append(or, clause.Expr{SQL: "? <% ?", Vars: []interface{}{keyword, searchColumn(column)}})
+	}
+
+	return clause.Or(or...)
+}
+
+func (PostgresTrigramSearch) Rank(columns []SearchColumn, keyword string) clause.Expression {
+	var sql []string
+	var vars []interface{}
+	for _, column := range columns {
+		sql = append(sql, fmt.Sprintf("word_similarity(?, ?) * %g", searchWeight(column)))
+		vars = append(vars, keyword, searchColumn(column))
+	}
+
+	return clause.Expr{SQL: "(" + strings.Join(sql, " + ") + ")", Vars: vars}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/anoaland/xgo/internal/testdb"
+)
+
+type searchProduct struct {
+	ID   uint
+	Name string
+	Code string
+}
+
+func TestLikeSearchEscapesWildcards(t *testing.T) {
+	db := testdb.New(t, &searchProduct{})
+
+	products := []searchProduct{
+		{Name: "50% off", Code: "A_1"}, {Name: "500 off", Code: "AB1"}, {Name: "Bang! [new]", Code: "C!"},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	search := Search{Columns: []SearchColumn{{Column: "name"}, {Column: "code", Weight: 2}}}
+	tests := []struct {
+		keyword string
+		want    []string
+	}{
+		{"50%", []string{"50% off"}},
+		{"a_1", []string{"50% off"}},
+		{"!", []string{"Bang! [new]"}},
+		{"[new]", []string{"Bang! [new]"}},
+		{"OFF", []string{"50% off", "500 off"}},
+	}
+
+	for _, test := range tests {
+		var found []searchProduct
+		if err := db.Scopes(search.Scope(test.keyword)).Order("id").Find(&found).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if len(found) != len(test.want) {
+			t.Fatalf("expected %v for %q, got %+v", test.want, test.keyword, found)
+		}
+		for i, product := range found {
+			if product.Name != test.want[i] {
+				t.Fatalf("expected %v for %q, got %+v", test.want, test.keyword, found)
+			}
+		}
+	}
+}
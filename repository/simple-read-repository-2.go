@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/db/replica"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +18,12 @@ func NewSimpleReadRepo2[M interface{}, D SimpleReadIDto2[M, D]](context *gorm.DB
 	}
 }
 
+// UsePrimary returns a repository reading from the primary database when read
+// replicas are registered. See replica.UsePrimary.
+func (r *SimpleReadRepository2[M, D]) UsePrimary() *SimpleReadRepository2[M, D] {
+	return NewSimpleReadRepo2[M, D](replica.UsePrimary(r.db))
+}
+
 func (r *SimpleReadRepository2[M, D]) findAll(conds *string, orderBy *string, args ...interface{}) ([]D, error) {
 	model := new(*M)
 	var rows *[]M
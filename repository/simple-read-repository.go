@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/db/replica"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +18,12 @@ func NewSimpleReadRepo[M interface{}, D SimpleReadIDto[M, D]](context *gorm.DB)
 	}
 }
 
+// UsePrimary returns a repository reading from the primary database when read
+// replicas are registered. See replica.UsePrimary.
+func (r *SimpleReadRepository[M, D]) UsePrimary() *SimpleReadRepository[M, D] {
+	return NewSimpleReadRepo[M, D](replica.UsePrimary(r.db))
+}
+
 func (r *SimpleReadRepository[M, D]) FindAll(conds ...interface{}) ([]D, error) {
 	var rows *[]M
 	if err := r.db.Find(&rows, conds).Error; err != nil {
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+type unitOfWorkKey struct{}
+
+// UnitOfWork is a transaction shared by the repositories bound to it with
+// Tx(uow.DB()).
+type UnitOfWork interface {
+	// DB returns the transaction.
+	DB() *gorm.DB
+
+	// Context returns the context of the transaction, carrying the unit of
+	// work, so WithTransaction joins it with a savepoint.
+	Context() context.Context
+
+	// Transaction runs fn within a savepoint, only rolled back to the
+	// savepoint when fn fails.
+	Transaction(fn func(uow UnitOfWork) error) error
+
+	// AfterCommit registers fn to run after the outermost transaction
+	// committed, e.g. to publish events. It is dropped on rollback.
+	AfterCommit(fn func(ctx context.Context))
+}
+
+type TransactionOptions struct {
+	// Isolation is the isolation level of the transaction.
+	// Optional. Default: the default of the database.
+	Isolation sql.IsolationLevel
+
+	ReadOnly bool
+
+	// MaxRetries is the number of times the transaction is run again after a
+	// serialization failure or a deadlock, see IsRetryable.
+	// Optional. Default: 3. A negative value disables retries.
+	MaxRetries int
+
+	// RetryDelay is the delay before the first retry, doubled for every
+	// following one, with jitter.
+	// Optional. Default: 50ms.
+	RetryDelay time.Duration
+}
+
+type unitOfWork struct {
+	tx         *gorm.DB
+	ctx        context.Context
+	savepoints *atomic.Int32
+	hooks      []func(ctx context.Context)
+}
+
+// WithTransaction runs fn in a transaction, committed when fn returns nil and
+// rolled back when it returns an error or panics, the panic being resumed.
+// Serialization failures and deadlocks run fn again in a new transaction, so
+// fn must not have side effects outside of it; use AfterCommit for those.
+//
+// Within a unit of work, i.e. with a ctx from UnitOfWork.Context,
+// WithTransaction runs fn within a savepoint of the current transaction.
+//
+//	err := repository.WithTransaction(ctx, db, func(uow repository.UnitOfWork) error {
+//		order, err := orders.Tx(uow.DB()).Create(payload)
+//		if err != nil {
+//			return err
+//		}
+//		uow.AfterCommit(func(ctx context.Context) { publishOrderCreated(ctx, order) })
+//		return stock.Tx(uow.DB()).Update(reservation, "id = ?", payload.ItemID)
+//	})
+func WithTransaction(ctx context.Context, db *gorm.DB, fn func(uow UnitOfWork) error, options ...TransactionOptions) error {
+	if current, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok {
+		return current.Transaction(fn)
+	}
+
+	var opts TransactionOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 50 * time.Millisecond
+	}
+
+	delay := opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		uow, err := runTransaction(ctx, db, fn, opts)
+		if err == nil {
+			for _, hook := range uow.hooks {
+				hook(ctx)
+			}
+			return nil
+		}
+
+		if attempt >= opts.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+
+		db.Logger.Warn(ctx, "Retrying transaction after: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay + time.Duration(rand.Int63n(int64(delay)))):
+		}
+		delay *= 2
+	}
+}
+
+func runTransaction(ctx context.Context, db *gorm.DB, fn func(uow UnitOfWork) error, opts TransactionOptions) (uow *unitOfWork, err error) {
+	var txOptions *sql.TxOptions
+	if opts.Isolation != sql.LevelDefault || opts.ReadOnly {
+		txOptions = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	}
+
+	tx := db.WithContext(ctx).Begin(txOptions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	uow = &unitOfWork{tx: tx, savepoints: &atomic.Int32{}}
+	uow.ctx = context.WithValue(ctx, unitOfWorkKey{}, uow)
+	uow.tx = tx.WithContext(uow.ctx)
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(uow); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	committed = true
+
+	return uow, nil
+}
+
+func (u *unitOfWork) DB() *gorm.DB {
+	return u.tx
+}
+
+func (u *unitOfWork) Context() context.Context {
+	return u.ctx
+}
+
+func (u *unitOfWork) AfterCommit(fn func(ctx context.Context)) {
+	u.hooks = append(u.hooks, fn)
+}
+
+func (u *unitOfWork) Transaction(fn func(uow UnitOfWork) error) (err error) {
+	name := fmt.Sprintf("xgo_sp_%d", u.savepoints.Add(1))
+	if err := u.tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	nested := &unitOfWork{tx: u.tx, ctx: u.ctx, savepoints: u.savepoints}
+
+	completed := false
+	defer func() {
+		if !completed {
+			u.tx.RollbackTo(name)
+		}
+	}()
+
+	if err = fn(nested); err != nil {
+		return err
+	}
+	completed = true
+
+	u.hooks = append(u.hooks, nested.hooks...)
+
+	return nil
+}
+
+// IsRetryable reports whether err is a serialization failure or a deadlock,
+// after which a transaction can succeed when run again.
+func IsRetryable(err error) bool {
+	// pgx
+	var sqlState interface{ SQLState() string }
+	if errors.As(err, &sqlState) {
+		switch sqlState.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+
+	// go-mssqldb: deadlock victim, snapshot isolation update conflict
+	var sqlServerErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &sqlServerErr) {
+		switch sqlServerErr.SQLErrorNumber() {
+		case 1205, 3960:
+			return true
+		}
+	}
+
+	// deadlock
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1213 {
+		return true
+	}
+
+	// SQLITE_BUSY, the sqlite3 driver requires cgo, so match its message
+	return strings.Contains(err.Error(), "database is locked")
+}
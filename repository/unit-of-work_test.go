@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/anoaland/xgo/internal/testdb"
+	"gorm.io/gorm"
+)
+
+type uowItem struct {
+	ID   uint
+	Name string
+}
+
+type uowItemDto struct {
+	ID   uint
+	Name string
+}
+
+func (d uowItemDto) ToModel() *uowItem {
+	return &uowItem{ID: d.ID, Name: d.Name}
+}
+
+func (uowItemDto) FromModel(m *uowItem) *uowItemDto {
+	return &uowItemDto{ID: m.ID, Name: m.Name}
+}
+
+// deadlockError mimics a pgx error of a deadlock.
+type deadlockError struct{}
+
+func (deadlockError) Error() string    { return "deadlock detected" }
+func (deadlockError) SQLState() string { return "40P01" }
+
+func TestWithTransactionRetriesRepositoryDeadlocks(t *testing.T) {
+	db := testdb.New(t, &uowItem{})
+
+	failures := 1
+	err := db.Callback().Create().Before("gorm:create").Register("test:deadlock", func(tx *gorm.DB) {
+		if failures > 0 {
+			failures--
+			tx.AddError(deadlockError{})
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := Brief[uowItem, uowItemDto, uowItemDto](db)
+	attempts := 0
+	err = WithTransaction(context.Background(), db, func(uow UnitOfWork) error {
+		attempts++
+		_, err := repo.Tx(uow.DB()).Create(uowItemDto{Name: "retried"})
+		return err
+	}, TransactionOptions{RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected the deadlock to be retried, got %v", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+
+	count, err := repo.Count("name = ?", "retried")
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 row, got %d (%v)", count, err)
+	}
+}
+
+func TestIsRetryableRepositoryError(t *testing.T) {
+	err := repositoryError("E_REPO_CREATE", deadlockError{})
+	if !IsRetryable(err) {
+		t.Fatal("expected a repository wrapped deadlock to be retryable")
+	}
+
+	if IsRetryable(repositoryError("E_REPO_CREATE", errors.New("syntax error"))) {
+		t.Fatal("expected a syntax error not to be retryable")
+	}
+}
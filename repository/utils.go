@@ -22,8 +22,11 @@ func LowerLikeQuery(field string) string {
 	return "LOWER(" + field + ") LIKE ?"
 }
 
+// Deprecated: use Paginate, which returns errors and counts in the same query.
 func FilterPaginate(DB *gorm.DB, modelName interface{}, pagination *dto.Pagination, clauses []clause.Expression, joins []string) func(db *gorm.DB) *gorm.DB {
 	var totalRows int64
+	// filters validated by pagination.Allow
+	clauses = append(clauses[:len(clauses):len(clauses)], pagination.Clauses()...)
 	tx := DB.Model(modelName).Clauses(clauses...)
 
 	if len(joins) > 0 {
@@ -48,15 +51,15 @@ func FilterPaginate(DB *gorm.DB, modelName interface{}, pagination *dto.Paginati
 
 func txWithJoins(tx *gorm.DB, joinList []string) *gorm.DB {
 	for _, join := range joinList {
-		tx.Joins(join)
+		tx = tx.Joins(join)
 	}
 
 	return tx
 }
 
 func txWithPeloads(tx *gorm.DB, preloadList []string) *gorm.DB {
-	for _, join := range preloadList {
-		tx.Preload(join)
+	for _, preload := range preloadList {
+		tx = tx.Preload(preload)
 	}
 
 	return tx
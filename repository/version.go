@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// modelSchema returns the parsed schema of M.
+func modelSchema[M interface{}](db *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(M)); err != nil {
+		return nil, err
+	}
+
+	return stmt.Schema, nil
+}
+
+// versionField returns the integer field tagged `gorm:"version"` enabling
+// optimistic locking, or nil. A Version field without the tag is left alone,
+// it may hold domain data.
+func versionField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings["VERSION"]; !ok || field.DBName == "" {
+			continue
+		}
+
+		switch field.FieldType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return field
+		}
+	}
+
+	return nil
+}
+
+// initVersion sets the version of a new versioned row to 1.
+func initVersion[M interface{}](db *gorm.DB, values ...*M) error {
+	s, err := modelSchema[M](db)
+	if err != nil {
+		return err
+	}
+
+	field := versionField(s)
+	if field == nil {
+		return nil
+	}
+
+	for _, value := range values {
+		rv := reflect.ValueOf(value).Elem()
+		if _, zero := field.ValueOf(db.Statement.Context, rv); zero {
+			if err := field.Set(db.Statement.Context, rv, 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expectVersion returns the condition on the version of value, the expected
+// one, and increments it. It returns a nil condition for a model without
+// version and ErrVersionRequired for a value without version.
+func expectVersion[M interface{}](db *gorm.DB, value *M) (clause.Expression, error) {
+	s, err := modelSchema[M](db)
+	if err != nil {
+		return nil, err
+	}
+
+	field := versionField(s)
+	if field == nil {
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(value).Elem()
+	expected, zero := field.ValueOf(db.Statement.Context, rv)
+	if zero {
+		return nil, ErrVersionRequired
+	}
+
+	if err := field.Set(db.Statement.Context, rv, reflect.ValueOf(expected).Convert(reflect.TypeOf(int64(0))).Int()+1); err != nil {
+		return nil, err
+	}
+
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: expected}, nil
+}
+
+// versionedUpserts returns the assignments of an upsert of a versioned model,
+// incrementing the version of the existing row instead of overwriting it.
+func versionedUpserts(s *schema.Schema, field *schema.Field, updateColumns []string) clause.Set {
+	if len(updateColumns) == 0 {
+		for _, f := range s.Fields {
+			if f.DBName != "" && f.Updatable && !f.PrimaryKey && f != field && f.AutoCreateTime == 0 {
+				updateColumns = append(updateColumns, f.DBName)
+			}
+		}
+	}
+
+	var columns []string
+	for _, column := range updateColumns {
+		if column != field.DBName {
+			columns = append(columns, column)
+		}
+	}
+
+	return append(clause.AssignmentColumns(columns), clause.Assignment{
+		Column: clause.Column{Name: field.DBName},
+		Value:  clause.Expr{SQL: "? + 1", Vars: []interface{}{clause.Column{Table: s.Table, Name: field.DBName}}},
+	})
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/internal/testdb"
+	"github.com/gofiber/fiber/v2"
+)
+
+type versionedDoc struct {
+	ID      uint
+	Title   string
+	Version uint `gorm:"version"`
+}
+
+func (d versionedDoc) ToModel() *versionedDoc {
+	return &d
+}
+
+func (versionedDoc) FromModel(m *versionedDoc) *versionedDoc {
+	return m
+}
+
+// plainDoc has a Version field holding domain data, without optimistic locking.
+type plainDoc struct {
+	ID      uint
+	Title   string
+	Version int
+}
+
+func (d plainDoc) ToModel() *plainDoc {
+	return &d
+}
+
+func (plainDoc) FromModel(m *plainDoc) *plainDoc {
+	return m
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	repo := Brief[versionedDoc, versionedDoc, versionedDoc](testdb.New(t, &versionedDoc{}))
+
+	doc, err := repo.Create(versionedDoc{Title: "draft"})
+	if err != nil || doc.Version != 1 {
+		t.Fatalf("expected a new row at version 1, got %+v (%v)", doc, err)
+	}
+
+	if err := repo.Update(versionedDoc{Title: "first", Version: 1}, "id = ?", doc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		payload versionedDoc
+		err     error
+		status  int
+	}{
+		{"stale version", versionedDoc{Title: "stale", Version: 1}, ErrVersionConflict, fiber.StatusConflict},
+		{"missing version", versionedDoc{Title: "blind"}, ErrVersionRequired, fiber.StatusPreconditionRequired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := repo.Update(test.payload, "id = ?", doc.ID)
+			if !errors.Is(err, test.err) || xgo.AsXgoError(err).HttpErrorCode != test.status {
+				t.Fatalf("expected %v, a %d, got %v", test.err, test.status, err)
+			}
+		})
+	}
+
+	doc, err = repo.FindByID(doc.ID)
+	if err != nil || doc.Title != "first" || doc.Version != 2 {
+		t.Fatalf("expected only the first update at version 2, got %+v (%v)", doc, err)
+	}
+}
+
+func TestUntaggedVersionIsNotLocked(t *testing.T) {
+	repo := Brief[plainDoc, plainDoc, plainDoc](testdb.New(t, &plainDoc{}))
+
+	doc, err := repo.Create(plainDoc{Title: "draft"})
+	if err != nil || doc.Version != 0 {
+		t.Fatalf("expected the version to be left alone, got %+v (%v)", doc, err)
+	}
+
+	if err := repo.Update(plainDoc{Title: "first"}, "id = ?", doc.ID); err != nil {
+		t.Fatalf("expected an update without version, got %v", err)
+	}
+
+	if err := repo.Update(plainDoc{Version: 7}, "id = ?", doc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err = repo.FindByID(doc.ID)
+	if err != nil || doc.Title != "first" || doc.Version != 7 {
+		t.Fatalf("expected the version to be written as is, got %+v (%v)", doc, err)
+	}
+}
+
+func TestVersionETag(t *testing.T) {
+	repo := Brief[versionedDoc, versionedDoc, versionedDoc](testdb.New(t, &versionedDoc{}))
+
+	doc, err := repo.Create(versionedDoc{Title: "draft"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		return c.SendStatus(xgo.AsXgoError(err).HttpErrorCode)
+	}})
+	app.Get("/docs/:id", func(c *fiber.Ctx) error {
+		doc, err := repo.FindByID(c.Params("id"))
+		if err != nil {
+			return err
+		}
+		xgo.SetVersionETag(c, int64(doc.Version))
+		return c.JSON(doc)
+	})
+	app.Put("/docs/:id", func(c *fiber.Ctx) error {
+		version, err := xgo.IfMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		return repo.Update(versionedDoc{Title: "updated", Version: uint(version)}, "id = ?", c.Params("id"))
+	})
+
+	send := func(method string, ifMatch string) (int, string) {
+		t.Helper()
+
+		req := httptest.NewRequest(method, "/docs/1", nil)
+		if ifMatch != "" {
+			req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.StatusCode, res.Header.Get(fiber.HeaderETag)
+	}
+
+	if _, etag := send(fiber.MethodGet, ""); etag != `"1"` {
+		t.Fatalf(`expected the ETag "1" of %+v, got %s`, doc, etag)
+	}
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+	}{
+		{"missing", "", fiber.StatusPreconditionRequired},
+		{"malformed", "1", fiber.StatusPreconditionFailed},
+		{"not a version", `"abc"`, fiber.StatusPreconditionFailed},
+		{"current", `"1"`, fiber.StatusOK},
+		{"stale", `"1"`, fiber.StatusConflict},
+		{"weak", `W/"2"`, fiber.StatusOK},
+	}
+
+	for _, test := range tests {
+		if status, _ := send(fiber.MethodPut, test.ifMatch); status != test.status {
+			t.Fatalf("expected a %d for the %s If-Match, got %d", test.status, test.name, status)
+		}
+	}
+
+	if _, etag := send(fiber.MethodGet, ""); etag != `"3"` {
+		t.Fatalf(`expected the ETag "3" after two updates, got %s`, etag)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anoaland/xgo/dto"
+	"gorm.io/gorm"
+)
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// BindWhitelist returns a copy of whitelist converting the filter values
+// parsed from query strings to the types of the fields of M, matched by
+// column name, e.g. "2024-01-01" to a time.Time for a `createdAt` filter.
+// Columns of joined tables and fields with a Coerce are left as is.
+func BindWhitelist[M interface{}](db *gorm.DB, whitelist dto.Whitelist) (dto.Whitelist, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(M)); err != nil {
+		return nil, err
+	}
+
+	bound := make(dto.Whitelist, len(whitelist))
+	for name, field := range whitelist {
+		if field.Coerce == nil {
+			column := field.Column
+			if dot := strings.LastIndex(column, "."); dot >= 0 {
+				if column[:dot] != stmt.Schema.Table {
+					bound[name] = field
+					continue
+				}
+				column = column[dot+1:]
+			}
+
+			if schemaField := stmt.Schema.LookUpField(column); schemaField != nil {
+				field.Coerce = coerceTo(schemaField.FieldType)
+			}
+		}
+
+		bound[name] = field
+	}
+
+	return bound, nil
+}
+
+// coerceTo returns the conversion of strings to t, nil to keep strings.
+func coerceTo(t reflect.Type) func(string) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(sql.NullTime{}), reflect.TypeOf(gorm.DeletedAt{}):
+		return parseTime
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(value string) (interface{}, error) {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a boolean", value)
+			}
+			return parsed, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(value string) (interface{}, error) {
+			parsed, err := strconv.ParseInt(value, 10, t.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not an integer", value)
+			}
+			return parsed, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(value string) (interface{}, error) {
+			parsed, err := strconv.ParseUint(value, 10, t.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a positive integer", value)
+			}
+			return parsed, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(value string) (interface{}, error) {
+			parsed, err := strconv.ParseFloat(value, t.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a number", value)
+			}
+			return parsed, nil
+		}
+	}
+
+	return nil
+}
+
+func parseTime(value string) (interface{}, error) {
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return nil, errors.New("'" + value + "' is not a date, expected e.g. 2024-01-31 or 2024-01-31T10:00:00Z")
+}
@@ -7,8 +7,8 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"github.com/anoaland/xgo/internal"
 	"github.com/gofiber/fiber/v2"
 
 	auth "github.com/anoaland/xgo/auth"
@@ -38,6 +38,15 @@ func (xr XRouter) WithAuth(prefix string) *XRouter {
 	}
 }
 
+// WithSlowThreshold creates a group under prefix whose requests are logged as
+// slow when they take longer than threshold, see SlowThreshold.
+func (xr XRouter) WithSlowThreshold(prefix string, threshold time.Duration) *XRouter {
+	return &XRouter{
+		xr.Group(prefix, SlowThreshold(threshold)),
+		xr.ws,
+	}
+}
+
 func (xr XRouter) XGroup(prefix string) *XRouter {
 	return &XRouter{
 		xr.Group(prefix),
@@ -130,19 +139,9 @@ func (server *WebServer) RunOnAddress(addr string, onShutdown func() error) {
 	}
 }
 
+// LoggerContext returns the request context carrying the per-request logger,
+// to be passed to GORM with `db.WithContext(...)` so SQL logs share the request_id.
+// See RequestContext.
 func (server *WebServer) LoggerContext(ctx *fiber.Ctx) context.Context {
-	// Get the per-request logger with request_id context
-	requestLogger := GetRequestLogger(ctx)
-	if requestLogger == nil {
-		// Fallback to basic context if no request logger is available
-		return context.WithValue(context.Background(), internal.FiberContextKey, ctx)
-	}
-
-	// Create a context with the request logger embedded
-	loggerCtx := requestLogger.WithContext(context.Background())
-
-	// Also store the fiber context for the GORM logger to access
-	loggerCtx = context.WithValue(loggerCtx, internal.FiberContextKey, ctx)
-
-	return loggerCtx
+	return RequestContext(ctx)
 }
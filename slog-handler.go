@@ -0,0 +1,149 @@
+package xgo
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/anoaland/xgo/internal"
+	"github.com/rs/zerolog"
+)
+
+// SlogHandler is a log/slog handler writing into the per-request zerolog logger
+// carried by the context, so libraries using the standard `log/slog` share the
+// request_id of the application logs:
+//
+//	slog.SetDefault(xgo.NewSlogLogger())
+//
+//	// anywhere with the request context
+//	slog.InfoContext(ctx, "cache miss", "key", key)
+//
+// When the context carries no logger, the handler logger is used.
+type SlogHandler struct {
+	logger *zerolog.Logger
+	attrs  []slog.Attr
+	prefix string
+}
+
+// NewSlogHandler creates a SlogHandler. The optional logger is used when the
+// context carries none, defaulting to the same fallback as LoggerFrom.
+func NewSlogHandler(logger ...*zerolog.Logger) *SlogHandler {
+	h := &SlogHandler{}
+	if len(logger) > 0 {
+		h.logger = logger[0]
+	}
+
+	return h
+}
+
+// NewSlogLogger creates a slog.Logger backed by a SlogHandler.
+func NewSlogLogger(logger ...*zerolog.Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(logger...))
+}
+
+func (h *SlogHandler) loggerFrom(ctx context.Context) *zerolog.Logger {
+	if logger := internal.LoggerFromContext(ctx); logger != nil {
+		return logger
+	}
+
+	if h.logger != nil {
+		return h.logger
+	}
+
+	return fallbackLogger()
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	zlevel := zerologLevel(level)
+	return zlevel >= h.loggerFrom(ctx).GetLevel() && zlevel >= zerolog.GlobalLevel()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	event := newLevelEvent(h.loggerFrom(ctx), zerologLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	for _, attr := range h.attrs {
+		event = appendSlogAttr(event, "", attr)
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		event = appendSlogAttr(event, h.prefix, attr)
+		return true
+	})
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		event = event.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(frame.PC, frame.File, frame.Line))
+	}
+
+	event.Msg(record.Message)
+
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+
+	for _, attr := range attrs {
+		if h.prefix != "" {
+			attr.Key = h.prefix + attr.Key
+		}
+		clone.attrs = append(clone.attrs, attr)
+	}
+
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+
+	return &clone
+}
+
+// appendSlogAttr writes attr, flattening groups into dotted keys.
+func appendSlogAttr(event *zerolog.Event, prefix string, attr slog.Attr) *zerolog.Event {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+
+		for _, child := range attr.Value.Group() {
+			event = appendSlogAttr(event, groupPrefix, child)
+		}
+
+		return event
+	}
+
+	if attr.Key == "" {
+		return event
+	}
+
+	return appendEventField(event, prefix+attr.Key, attr.Value.Any())
+}
+
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	}
+
+	return zerolog.ErrorLevel
+}
@@ -0,0 +1,194 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/anoaland/xgo"
+	"github.com/anoaland/xgo/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+const TENANT_LOCAL_KEY = "x-tenant"
+
+type tenantContextKey struct{}
+
+type bypassContextKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant id, e.g. for a
+// background job working for a tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// From returns the tenant id of a context.Context, such as the one returned
+// by xgo.RequestContext, or an empty string.
+func From(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
+
+// WithBypass returns a copy of ctx whose statements are not scoped to a
+// tenant, e.g. for an admin job working across tenants. See also Bypass.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassContextKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	bypass, _ := ctx.Value(bypassContextKey{}).(bool)
+	return bypass
+}
+
+// Resolver returns the tenant id of a request, or an empty string when the
+// request does not name one.
+type Resolver func(c *fiber.Ctx) (string, error)
+
+// FromHeader resolves the tenant from a request header, e.g. "X-Tenant-ID".
+func FromHeader(name string) Resolver {
+	return func(c *fiber.Ctx) (string, error) {
+		return strings.TrimSpace(c.Get(name)), nil
+	}
+}
+
+// FromSubdomain resolves the tenant from the subdomain of baseDomain, e.g.
+// "acme" for "acme.example.com" with the base domain "example.com".
+func FromSubdomain(baseDomain string) Resolver {
+	suffix := "." + strings.TrimPrefix(strings.ToLower(baseDomain), ".")
+
+	return func(c *fiber.Ctx) (string, error) {
+		host := strings.ToLower(c.Hostname())
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		if !strings.HasSuffix(host, suffix) {
+			return "", nil
+		}
+
+		subdomain := strings.TrimSuffix(host, suffix)
+		if subdomain == "" || strings.Contains(subdomain, ".") {
+			return "", nil
+		}
+
+		return subdomain, nil
+	}
+}
+
+// FromClaim resolves the tenant from a claim of the user verified by the auth
+// middleware, see auth.UserFrom, e.g. "tenant_id". The user is either a map of
+// claims or a struct whose JSON has the claim, so the middleware must run
+// after the auth middleware.
+func FromClaim(claim string) Resolver {
+	return func(c *fiber.Ctx) (string, error) {
+		user := auth.UserFrom(c.UserContext())
+		if user == nil {
+			return "", nil
+		}
+
+		claims, ok := user.(map[string]interface{})
+		if !ok {
+			payload, err := json.Marshal(user)
+			if err != nil {
+				return "", fmt.Errorf("unsupported user: %w", err)
+			}
+
+			if err := json.Unmarshal(payload, &claims); err != nil {
+				return "", errors.New("the user has no claims")
+			}
+		}
+
+		switch value := claims[claim].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return value, nil
+		case float64:
+			return fmt.Sprintf("%.0f", value), nil
+		case int, int64, uint, uint64:
+			return fmt.Sprint(value), nil
+		default:
+			return "", fmt.Errorf("unsupported '%s' claim", claim)
+		}
+	}
+}
+
+type MiddlewareConfig struct {
+	// Resolvers resolve the tenant of a request, the first one returning a
+	// tenant wins.
+	// Required.
+	Resolvers []Resolver
+
+	// Authorize checks the tenant is allowed for the request, e.g. that the
+	// authenticated user belongs to it, and returns an error otherwise. Any
+	// client can send the header or the subdomain of another tenant.
+	// Required, unless TrustResolvers is set.
+	Authorize func(c *fiber.Ctx, tenantID string) error
+
+	// TrustResolvers accepts the resolved tenant without Authorize, only when
+	// the resolvers can not be chosen by the client, e.g. FromClaim.
+	// Optional. Default: false.
+	TrustResolvers bool
+
+	// Optional allows requests without tenant, whose statements on tenant
+	// scoped models then fail.
+	// Optional. Default: false, a request without tenant is rejected with a 400.
+	Optional bool
+}
+
+// Middleware resolves the tenant of every request into its context, see From
+// and xgo.RequestContext, and its locals, see TENANT_LOCAL_KEY. It panics
+// without resolvers, or without Authorize unless TrustResolvers is set.
+func Middleware(cfg MiddlewareConfig) fiber.Handler {
+	if len(cfg.Resolvers) == 0 {
+		panic("tenant: the middleware requires at least one resolver")
+	}
+
+	if cfg.Authorize == nil && !cfg.TrustResolvers {
+		panic("tenant: the middleware requires Authorize, or TrustResolvers for resolvers which the client can not choose")
+	}
+
+	return func(c *fiber.Ctx) error {
+		var tenantID string
+		for _, resolve := range cfg.Resolvers {
+			resolved, err := resolve(c)
+			if err != nil {
+				return xgo.NewHttpBadRequestError("TENANT__RESOLVE", err)
+			}
+
+			if resolved != "" {
+				tenantID = resolved
+				break
+			}
+		}
+
+		if tenantID == "" {
+			if cfg.Optional {
+				return c.Next()
+			}
+			return xgo.NewHttpBadRequestError("TENANT__MISSING", errors.New("the tenant of the request is required"))
+		}
+
+		if cfg.Authorize != nil {
+			if err := cfg.Authorize(c, tenantID); err != nil {
+				return xgo.NewHttpForbiddenError("TENANT__FORBIDDEN", err)
+			}
+		}
+
+		c.Locals(TENANT_LOCAL_KEY, tenantID)
+		c.SetUserContext(WithTenant(c.UserContext(), tenantID))
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,343 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const pluginName = "xgo:tenant"
+
+var (
+	// ErrNoTenant is returned by the statements on a tenant scoped model
+	// without tenant in their context, see WithTenant and Bypass.
+	ErrNoTenant = errors.New("tenant: the statement has no tenant")
+
+	// ErrCrossTenant is returned when creating a row of another tenant, or
+	// upserting a row with a dialect which can not scope the update to the tenant.
+	ErrCrossTenant = errors.New("tenant: the row belongs to another tenant")
+)
+
+type Config struct {
+	// Field is the name of the tenant field of the scoped models. The models
+	// without it are not scoped.
+	// Optional. Default: "TenantID".
+	Field string
+}
+
+// Scope scopes the statements of the models with a tenant field to the tenant
+// of their context: reads, updates and deletes are filtered by the tenant,
+// creates set it and updates can not change it. Raw SQL is not scoped.
+type Scope struct {
+	config Config
+}
+
+// Register installs a Scope on db. The statements on scoped models must then
+// run with a context carrying a tenant, see gorm.DB.WithContext, or be
+// bypassed. They fail with ErrNoTenant otherwise.
+func Register(db *gorm.DB, config ...Config) (*Scope, error) {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	if cfg.Field == "" {
+		cfg.Field = "TenantID"
+	}
+
+	s := &Scope{config: cfg}
+	if err := db.Use(s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Bypass returns a session whose statements are not scoped to a tenant, e.g.
+// for an admin job working across tenants. The bypass is set on its context,
+// see WithBypass, so that it also applies to the statements of plugins, e.g.
+// audit, running in new sessions of it.
+func Bypass(db *gorm.DB) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return db.WithContext(WithBypass(ctx))
+}
+
+func (s *Scope) Name() string {
+	return pluginName
+}
+
+func (s *Scope) Initialize(db *gorm.DB) error {
+	callbacks := []error{
+		db.Callback().Query().Before("gorm:query").Register(pluginName+":query", s.filter),
+		db.Callback().Row().Before("gorm:row").Register(pluginName+":row", s.filter),
+		db.Callback().Create().Before("gorm:create").Register(pluginName+":create", s.assign),
+		db.Callback().Update().Before("gorm:update").Register(pluginName+":update", s.filterUpdate),
+		db.Callback().Delete().Before("gorm:delete").Register(pluginName+":delete", s.filterDelete),
+	}
+	for _, err := range callbacks {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tenant returns the tenant field of the model of the statement and the
+// tenant converted to its type, or a nil field when the statement is not scoped.
+func (s *Scope) tenant(db *gorm.DB) (*schema.Field, interface{}, error) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil, nil, nil
+	}
+
+	field := db.Statement.Schema.LookUpField(s.config.Field)
+	if field == nil || field.DBName == "" {
+		return nil, nil, nil
+	}
+
+	if bypassed(db.Statement.Context) {
+		return nil, nil, nil
+	}
+
+	tenantID := From(db.Statement.Context)
+	if tenantID == "" {
+		return nil, nil, ErrNoTenant
+	}
+
+	value, err := tenantValue(field, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return field, value, nil
+}
+
+func (s *Scope) filter(db *gorm.DB) {
+	s.where(db)
+}
+
+// filterUpdate filters an update by the tenant and omits the tenant field,
+// so that a row can not be moved to another tenant.
+func (s *Scope) filterUpdate(db *gorm.DB) {
+	if !s.checkConditions(db) {
+		return
+	}
+
+	if field := s.where(db); field != nil {
+		db.Statement.Omits = append(db.Statement.Omits, field.DBName)
+	}
+}
+
+func (s *Scope) filterDelete(db *gorm.DB) {
+	if s.checkConditions(db) {
+		s.where(db)
+	}
+}
+
+// checkConditions fails an update or a delete without conditions, which gorm
+// rejects with ErrMissingWhereClause but would run once filtered by the
+// tenant, writing all the rows of the tenant.
+func (s *Scope) checkConditions(db *gorm.DB) bool {
+	if db.Error != nil || db.AllowGlobalUpdate || db.Statement.Schema == nil || hasConditions(db) {
+		return true
+	}
+
+	if field, _, _ := s.tenant(db); field != nil {
+		db.AddError(gorm.ErrMissingWhereClause)
+		return false
+	}
+
+	return true
+}
+
+// hasConditions reports whether the statement has a WHERE clause or a model
+// with a primary key, which gorm turns into a condition.
+func hasConditions(db *gorm.DB) bool {
+	if where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+		return true
+	}
+
+	for _, value := range []reflect.Value{db.Statement.ReflectValue, reflect.ValueOf(db.Statement.Dest)} {
+		value = reflect.Indirect(value)
+		if !value.IsValid() {
+			continue
+		}
+
+		valueType := value.Type()
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			valueType = valueType.Elem()
+			for valueType.Kind() == reflect.Pointer {
+				valueType = valueType.Elem()
+			}
+		}
+
+		if valueType != db.Statement.Schema.ModelType {
+			continue
+		}
+
+		if _, values := schema.GetIdentityFieldValuesMap(db.Statement.Context, value, db.Statement.Schema.PrimaryFields); len(values) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// where adds the tenant condition to the statement and returns the tenant
+// field, or nil when the statement is not scoped.
+func (s *Scope) where(db *gorm.DB) *schema.Field {
+	field, value, err := s.tenant(db)
+	if err != nil {
+		db.AddError(err)
+		return nil
+	}
+
+	if field != nil {
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value},
+		}})
+	}
+
+	return field
+}
+
+func (s *Scope) assign(db *gorm.DB) {
+	field, value, err := s.tenant(db)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	if field == nil {
+		return
+	}
+
+	s.scopeUpsert(db, field)
+
+	if values, ok := db.Statement.Dest.(map[string]interface{}); ok {
+		values[field.DBName] = value
+		return
+	}
+
+	ctx := db.Statement.Context
+	assignRow := func(row reflect.Value) {
+		current, zero := field.ValueOf(ctx, row)
+		if zero {
+			if err := field.Set(ctx, row, value); err != nil {
+				db.AddError(err)
+			}
+		} else if fmt.Sprint(reflect.Indirect(reflect.ValueOf(current))) != fmt.Sprint(value) {
+			db.AddError(ErrCrossTenant)
+		}
+	}
+
+	rows := reflect.Indirect(db.Statement.ReflectValue)
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			if row := reflect.Indirect(rows.Index(i)); row.Kind() == reflect.Struct {
+				assignRow(row)
+			}
+		}
+	case reflect.Struct:
+		assignRow(rows)
+	}
+}
+
+// scopeUpsert prevents an upsert from updating the conflicting row of
+// another tenant: the tenant field is never updated and the row is only
+// updated when its tenant is the one of the inserted row. Dialects without
+// a condition on the update of an upsert, e.g. MySQL, fail with ErrCrossTenant.
+func (s *Scope) scopeUpsert(db *gorm.DB, field *schema.Field) {
+	c, ok := db.Statement.Clauses["ON CONFLICT"]
+	if !ok {
+		return
+	}
+
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing {
+		return
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres", "sqlite":
+	default:
+		db.AddError(ErrCrossTenant)
+		return
+	}
+
+	if onConflict.UpdateAll {
+		onConflict.UpdateAll = false
+		var columns []string
+		for _, f := range db.Statement.Schema.Fields {
+			if f.DBName != "" && f.Updatable && f.Creatable && !f.PrimaryKey && f.AutoCreateTime == 0 {
+				columns = append(columns, f.DBName)
+			}
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(columns)
+	}
+
+	doUpdates := onConflict.DoUpdates[:0:0]
+	for _, assignment := range onConflict.DoUpdates {
+		if assignment.Column.Name != field.DBName {
+			doUpdates = append(doUpdates, assignment)
+		}
+	}
+	onConflict.DoUpdates = doUpdates
+
+	if len(onConflict.DoUpdates) == 0 {
+		onConflict.DoNothing = true
+		db.Statement.AddClause(onConflict)
+		return
+	}
+
+	onConflict.Where.Exprs = append(onConflict.Where.Exprs, clause.Expr{
+		SQL: "? = ?",
+		Vars: []interface{}{
+			clause.Column{Table: db.Statement.Table, Name: field.DBName},
+			clause.Column{Table: "excluded", Name: field.DBName},
+		},
+	})
+
+	db.Statement.AddClause(onConflict)
+}
+
+// tenantValue converts tenantID to the type of field, e.g. an integer.
+func tenantValue(field *schema.Field, tenantID string) (interface{}, error) {
+	fieldType := field.FieldType
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+
+	value := reflect.New(fieldType).Elem()
+	switch fieldType.Kind() {
+	case reflect.String:
+		value.SetString(tenantID)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(tenantID, 10, fieldType.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("tenant: invalid tenant '%s'", tenantID)
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(tenantID, 10, fieldType.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("tenant: invalid tenant '%s'", tenantID)
+		}
+		value.SetUint(parsed)
+	default:
+		return tenantID, nil
+	}
+
+	return value.Interface(), nil
+}
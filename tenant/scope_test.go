@@ -0,0 +1,240 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/anoaland/xgo/dto"
+	"github.com/anoaland/xgo/internal/testdb"
+	"github.com/anoaland/xgo/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type item struct {
+	ID       uint
+	TenantID string
+	Name     string
+}
+
+type itemDto struct {
+	ID       uint
+	TenantID string
+	Name     string
+}
+
+func (d itemDto) ToModel() *item {
+	return &item{ID: d.ID, TenantID: d.TenantID, Name: d.Name}
+}
+
+func (itemDto) FromModel(m *item) *itemDto {
+	return &itemDto{ID: m.ID, TenantID: m.TenantID, Name: m.Name}
+}
+
+var (
+	tenantA = WithTenant(context.Background(), "a")
+	tenantB = WithTenant(context.Background(), "b")
+)
+
+// newTestDB returns a scoped database with an item of each tenant.
+func newTestDB(t *testing.T) (db *gorm.DB, itemA *itemDto, itemB *itemDto) {
+	t.Helper()
+
+	db = testdb.New(t, &item{})
+
+	if _, err := Register(db); err != nil {
+		t.Fatal(err)
+	}
+
+	itemA, err := repo(db, tenantA).Create(itemDto{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	itemB, err = repo(db, tenantB).Create(itemDto{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, itemA, itemB
+}
+
+func repo(db *gorm.DB, ctx context.Context) *repository.BriefRepository[item, itemDto, itemDto] {
+	return repository.Brief[item, itemDto, itemDto](db).WithContext(ctx)
+}
+
+// allItems returns the items of all the tenants by id.
+func allItems(t *testing.T, db *gorm.DB) map[uint]item {
+	t.Helper()
+
+	var items []item
+	if err := Bypass(db).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	byID := map[uint]item{}
+	for _, i := range items {
+		byID[i.ID] = i
+	}
+
+	return byID
+}
+
+func TestCreateSetsTenant(t *testing.T) {
+	db, itemA, itemB := newTestDB(t)
+
+	if itemA.TenantID != "a" || itemB.TenantID != "b" {
+		t.Fatalf("expected the tenants a and b, got %q and %q", itemA.TenantID, itemB.TenantID)
+	}
+
+	_, err := repo(db, tenantA).Create(itemDto{Name: "foreign", TenantID: "b"})
+	if !errors.Is(err, ErrCrossTenant) {
+		t.Fatalf("expected ErrCrossTenant, got %v", err)
+	}
+
+	if err := db.WithContext(tenantA).Model(&item{}).Create(map[string]interface{}{"Name": "map"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var created item
+	Bypass(db).Where("name = ?", "map").First(&created)
+	if created.TenantID != "a" {
+		t.Fatalf("expected a map create to set the tenant a, got %q", created.TenantID)
+	}
+}
+
+func TestReadsAreIsolated(t *testing.T) {
+	db, itemA, itemB := newTestDB(t)
+	r := repo(db, tenantA)
+
+	items, err := r.FindAll(nil, "id")
+	if err != nil || len(items) != 1 || items[0].ID != itemA.ID {
+		t.Fatalf("expected the item of a only, got %v (%v)", items, err)
+	}
+
+	if _, err := r.FindByID(itemB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the item of b not to be found, got %v", err)
+	}
+
+	if count, err := r.Count(nil); err != nil || count != 1 {
+		t.Fatalf("expected a count of 1, got %d (%v)", count, err)
+	}
+
+	page, err := r.Paginate(&dto.Pagination{Page: 1, Limit: 10})
+	if err != nil || page.TotalData != 1 || len(page.Rows) != 1 || page.Rows[0].ID != itemA.ID {
+		t.Fatalf("expected a page with the item of a only, got %+v (%v)", page, err)
+	}
+
+	page, err = r.Paginate(&dto.Pagination{Page: 1, Limit: 10}, repository.PaginateOptions{SeparateCount: true})
+	if err != nil || page.TotalData != 1 || len(page.Rows) != 1 {
+		t.Fatalf("expected a separately counted page with the item of a only, got %+v (%v)", page, err)
+	}
+}
+
+func TestWritesAreIsolated(t *testing.T) {
+	db, itemA, itemB := newTestDB(t)
+	r := repo(db, tenantA)
+
+	if err := r.Update(itemDto{Name: "updated"}, "id = ?", itemB.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Patch(repository.Patch{"name": "patched"}, "id = ?", itemB.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the patch of the item of b to be not found, got %v", err)
+	}
+
+	if _, err := r.Patch(repository.Patch{"tenantId": "b"}, "id = ?", itemA.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Delete("id = ?", itemB.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items := allItems(t, db)
+	if b := items[itemB.ID]; b.Name != "b" || b.TenantID != "b" {
+		t.Fatalf("expected the item of b to be untouched, got %+v", b)
+	}
+
+	if a := items[itemA.ID]; a.TenantID != "a" {
+		t.Fatalf("expected the item of a to stay in a, got %+v", a)
+	}
+}
+
+func TestUpsertsAreIsolated(t *testing.T) {
+	db, itemA, itemB := newTestDB(t)
+
+	err := db.WithContext(tenantA).Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&item{ID: itemB.ID, Name: "pwned"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo(db, tenantA).Upsert(itemDto{ID: itemB.ID, Name: "pwned"}, []string{"id"}, "name", "tenant_id")
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatal(err)
+	}
+
+	if _, err := repo(db, tenantA).Upsert(itemDto{ID: itemA.ID, Name: "upserted"}, []string{"id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	items := allItems(t, db)
+	if b := items[itemB.ID]; b.Name != "b" || b.TenantID != "b" {
+		t.Fatalf("expected the item of b to be untouched, got %+v", b)
+	}
+
+	if a := items[itemA.ID]; a.Name != "upserted" || a.TenantID != "a" {
+		t.Fatalf("expected the item of a to be upserted, got %+v", a)
+	}
+}
+
+func TestWritesWithoutConditionsFail(t *testing.T) {
+	db, _, _ := newTestDB(t)
+
+	if err := db.WithContext(tenantA).Model(&item{}).Update("name", "x").Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected ErrMissingWhereClause, got %v", err)
+	}
+
+	if err := db.WithContext(tenantA).Delete(&item{}).Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected ErrMissingWhereClause, got %v", err)
+	}
+
+	global := db.WithContext(tenantA).Session(&gorm.Session{AllowGlobalUpdate: true})
+	if err := global.Model(&item{}).Update("name", "all of a").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range allItems(t, db) {
+		if (i.TenantID == "a") != (i.Name == "all of a") {
+			t.Fatalf("expected a global update to only update the items of a, got %+v", i)
+		}
+	}
+}
+
+func TestNoTenant(t *testing.T) {
+	db, _, _ := newTestDB(t)
+
+	if _, err := repo(db, context.Background()).FindAll(nil, "id"); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+
+	if err := db.Create(&item{Name: "nobody"}).Error; !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+}
+
+func TestBypass(t *testing.T) {
+	db, _, _ := newTestDB(t)
+
+	var items []item
+	if err := Bypass(db).Find(&items).Error; err != nil || len(items) != 2 {
+		t.Fatalf("expected the items of all the tenants, got %v (%v)", items, err)
+	}
+
+	all, err := repo(db, WithBypass(context.Background())).FindAll(nil, "id")
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected the items of all the tenants, got %v (%v)", all, err)
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrAsyncWriterClosed = errors.New("async writer is closed")
+
+// AsyncWriter buffers log lines in memory and writes them to the underlying
+// writer from a single background goroutine, so logging never blocks a request.
+// When the buffer is full new lines are dropped and counted instead of waiting.
+//
+// Call Close on shutdown to flush the remaining lines.
+type AsyncWriter struct {
+	out     io.Writer
+	queue   chan []byte
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+	written atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// NewAsyncWriter starts an AsyncWriter in front of out holding at most
+// bufferSize pending lines. A bufferSize <= 0 defaults to 1024.
+func NewAsyncWriter(out io.Writer, bufferSize int) *AsyncWriter {
+	if bufferSize <= 0 {
+		bufferSize = 1024
+	}
+
+	w := &AsyncWriter{
+		out:   out,
+		queue: make(chan []byte, bufferSize),
+		done:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	for line := range w.queue {
+		if _, err := w.out.Write(line); err != nil {
+			w.failed.Add(1)
+			continue
+		}
+		w.written.Add(1)
+	}
+}
+
+// Write queues a copy of p and returns immediately. The caller may reuse p,
+// which zerolog does for every event.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	select {
+	case w.queue <- line:
+	default:
+		w.dropped.Add(1)
+	}
+
+	return len(p), nil
+}
+
+// Close stops accepting lines and waits until the queued ones are written.
+// The underlying writer is left open.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+
+	return nil
+}
+
+// Dropped returns the number of lines discarded because the buffer was full.
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Written returns the number of lines successfully written.
+func (w *AsyncWriter) Written() uint64 {
+	return w.written.Load()
+}
+
+// Failed returns the number of lines the underlying writer rejected.
+func (w *AsyncWriter) Failed() uint64 {
+	return w.failed.Load()
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrEnvRequired is wrapped by the EnvFieldError of a missing required field.
+var ErrEnvRequired = errors.New("is required")
+
+// EnvFieldError describes a field which could not be loaded or validated.
+type EnvFieldError struct {
+	Field string
+	Env   string
+	Err   error
+}
+
+func (e *EnvFieldError) Error() string {
+	if e.Env == "" {
+		return fmt.Sprintf("'%s' %v", e.Field, e.Err)
+	}
+
+	return fmt.Sprintf("'%s' (%s) %v", e.Field, e.Env, e.Err)
+}
+
+func (e *EnvFieldError) Unwrap() error {
+	return e.Err
+}
+
+// LoadEnvConfig populates the struct pointed by dst from environment variables
+// using struct tags:
+//
+//	type Config struct {
+//		Host    string        `env:"DB_HOST" required:"true"`
+//		Port    int           `env:"DB_PORT" default:"5432"`
+//		Timeout time.Duration `env:"DB_TIMEOUT" default:"5s"`
+//		Cache   CacheConfig   `envPrefix:"CACHE_"`
+//	}
+//
+// The optional prefix is prepended to every variable name, so the same struct
+// can be loaded for several databases, e.g. "REPORTING_" reads REPORTING_DB_HOST.
+// When a variable is not set, the content of the file named by the same
+// variable suffixed with `_FILE` is used instead, for mounted secrets.
+//
+// Supported field types are strings, booleans, numbers, time.Duration,
+// comma separated []string, encoding.TextUnmarshaler and nested structs.
+// All failures are returned together, joined with errors.Join.
+func LoadEnvConfig(dst any, prefix ...string) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("load env config: expected a pointer to a struct, got %T", dst)
+	}
+
+	envPrefix := ""
+	if len(prefix) > 0 {
+		envPrefix = prefix[0]
+	}
+
+	return errors.Join(loadEnvStruct(value.Elem(), envPrefix)...)
+}
+
+func loadEnvStruct(value reflect.Value, prefix string) []error {
+	var errs []error
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, hasEnv := field.Tag.Lookup("env")
+		if !hasEnv || name == "-" {
+			if fieldValue.Kind() == reflect.Struct && !isTextUnmarshaler(fieldValue) && name != "-" {
+				errs = append(errs, loadEnvStruct(fieldValue, prefix+field.Tag.Get("envPrefix"))...)
+			}
+			continue
+		}
+
+		env := prefix + name
+		raw, found, err := lookupEnv(env)
+		if err != nil {
+			errs = append(errs, &EnvFieldError{Field: field.Name, Env: env, Err: err})
+			continue
+		}
+
+		if !found {
+			raw, found = field.Tag.Lookup("default")
+		}
+
+		if !found || raw == "" {
+			if field.Tag.Get("required") == "true" && fieldValue.IsZero() {
+				errs = append(errs, &EnvFieldError{Field: field.Name, Env: env, Err: ErrEnvRequired})
+			}
+			continue
+		}
+
+		if err := setEnvField(fieldValue, raw); err != nil {
+			errs = append(errs, &EnvFieldError{Field: field.Name, Env: env, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// lookupEnv reads env, or the file named by env_FILE when env is not set.
+func lookupEnv(env string) (string, bool, error) {
+	if raw, ok := os.LookupEnv(env); ok {
+		return raw, true, nil
+	}
+
+	path, ok := os.LookupEnv(env + "_FILE")
+	if !ok || path == "" {
+		return "", false, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("can not read %s_FILE: %w", env, err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+func isTextUnmarshaler(value reflect.Value) bool {
+	if !value.CanAddr() {
+		return false
+	}
+
+	_, ok := value.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+func setEnvField(value reflect.Value, raw string) error {
+	if isTextUnmarshaler(value) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", value.Type())
+		}
+		parts := strings.Split(raw, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		value.Set(reflect.ValueOf(parts).Convert(value.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
+
+// ValidateRequired checks the fields tagged `required:"true"` of the struct
+// pointed by (or passed as) src are set. All missing fields are returned
+// together, joined with errors.Join.
+func ValidateRequired(src any) error {
+	value := reflect.ValueOf(src)
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validate required: expected a struct, got %T", src)
+	}
+
+	return errors.Join(validateRequiredStruct(value)...)
+}
+
+func validateRequiredStruct(value reflect.Value) []error {
+	var errs []error
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if _, hasEnv := field.Tag.Lookup("env"); !hasEnv && fieldValue.Kind() == reflect.Struct {
+			errs = append(errs, validateRequiredStruct(fieldValue)...)
+			continue
+		}
+
+		if field.Tag.Get("required") == "true" && fieldValue.IsZero() {
+			errs = append(errs, &EnvFieldError{Field: field.Name, Env: field.Tag.Get("env"), Err: ErrEnvRequired})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyValueWriter renders zerolog JSON lines as a single human readable line:
+//
+//	2024-01-02T15:04:05Z INF success request_id=... path=/api/users method=GET
+//
+// It is cheaper and more grep-friendly than JsonWriter, but still parses every
+// line, so prefer writing raw JSON in production.
+type KeyValueWriter struct {
+	Out io.Writer
+}
+
+// leading keys are rendered in this order without their key name
+var keyValueLeadingKeys = []string{"time", "level", "message"}
+
+func (w KeyValueWriter) Write(p []byte) (int, error) {
+	var obj map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		// not a json line, pass it through untouched
+		return w.Out.Write(p)
+	}
+
+	var buf bytes.Buffer
+
+	for _, key := range keyValueLeadingKeys {
+		value, ok := obj[key]
+		if !ok {
+			continue
+		}
+		delete(obj, key)
+
+		text := fmt.Sprint(value)
+		if key == "level" {
+			text = shortLevel(text)
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(text)
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(keyValueString(obj[key]))
+	}
+	buf.WriteByte('\n')
+
+	if _, err := w.Out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func keyValueString(value any) string {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return strconv.Quote(fmt.Sprint(v))
+		}
+		text = string(raw)
+	}
+
+	if text == "" || strings.ContainsAny(text, " \t\r\n\"=") {
+		return strconv.Quote(text)
+	}
+
+	return text
+}
+
+func shortLevel(level string) string {
+	switch level {
+	case "trace":
+		return "TRC"
+	case "debug":
+		return "DBG"
+	case "info":
+		return "INF"
+	case "warn":
+		return "WRN"
+	case "error":
+		return "ERR"
+	case "fatal":
+		return "FTL"
+	case "panic":
+		return "PNC"
+	}
+
+	return strings.ToUpper(level)
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatingFileTimeFormat = "20060102T150405.000"
+
+type RotatingFileWriterConfig struct {
+	// Filename is the file to write logs to. Rotated files are kept next to it
+	// as `<name>-<timestamp><ext>`.
+	Filename string
+
+	// MaxSize is the size in bytes after which the file is rotated.
+	// Optional. Default: 100 MB.
+	MaxSize int64
+
+	// MaxAge removes rotated files older than this duration.
+	// Optional. Default: 0, rotated files are never removed by age.
+	MaxAge time.Duration
+
+	// MaxBackups is the maximum number of rotated files to keep.
+	// Optional. Default: 0, all rotated files are kept.
+	MaxBackups int
+}
+
+// RotatingFileWriter is an io.Writer appending to a file which is rotated once
+// it grows past MaxSize. It is safe for concurrent use.
+type RotatingFileWriter struct {
+	config RotatingFileWriterConfig
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+}
+
+func NewRotatingFileWriter(config RotatingFileWriterConfig) (*RotatingFileWriter, error) {
+	if config.Filename == "" {
+		return nil, fmt.Errorf("rotating file writer: filename is required")
+	}
+
+	if config.MaxSize <= 0 {
+		config.MaxSize = 100 * 1024 * 1024
+	}
+
+	w := &RotatingFileWriter{config: config}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size > 0 && w.size+int64(len(p)) > w.config.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Rotate forces the current file to be rotated.
+func (w *RotatingFileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rotate()
+}
+
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+func (w *RotatingFileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.config.Filename), 0755); err != nil {
+		return fmt.Errorf("rotating file writer: %w", err)
+	}
+
+	file, err := os.OpenFile(w.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("rotating file writer: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("rotating file writer: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+
+	return nil
+}
+
+func (w *RotatingFileWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("rotating file writer: %w", err)
+		}
+		w.file = nil
+	}
+
+	prefix, ext := w.backupNameParts()
+	backup := prefix + time.Now().UTC().Format(rotatingFileTimeFormat) + ext
+	if err := os.Rename(w.config.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotating file writer: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.removeOldBackups()
+
+	return nil
+}
+
+func (w *RotatingFileWriter) backupNameParts() (string, string) {
+	ext := filepath.Ext(w.config.Filename)
+	return strings.TrimSuffix(w.config.Filename, ext) + "-", ext
+}
+
+// removeOldBackups applies MaxBackups and MaxAge. Failures are ignored, a
+// leftover backup must never stop logging.
+func (w *RotatingFileWriter) removeOldBackups() {
+	if w.config.MaxBackups <= 0 && w.config.MaxAge <= 0 {
+		return
+	}
+
+	prefix, ext := w.backupNameParts()
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if _, err := time.Parse(rotatingFileTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	// newest first, the timestamp format sorts lexically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	cutoff := time.Now().Add(-w.config.MaxAge)
+	for i, backup := range backups {
+		if w.config.MaxBackups > 0 && i >= w.config.MaxBackups {
+			os.Remove(backup)
+			continue
+		}
+
+		if w.config.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && info.ModTime().Before(cutoff) {
+				os.Remove(backup)
+			}
+		}
+	}
+}